@@ -8,6 +8,7 @@ import (
 	"github.com/maxb-odessa/nonsens/internal/config"
 	"github.com/maxb-odessa/nonsens/internal/sensors"
 	"github.com/maxb-odessa/nonsens/internal/server"
+	"github.com/maxb-odessa/nonsens/internal/storage"
 	"github.com/maxb-odessa/slog"
 
 	"github.com/pborman/getopt/v2"
@@ -60,6 +61,13 @@ func main() {
 		return
 	}
 
+	// open sensors values storage
+	if err := storage.Run(conf); err != nil {
+		slog.Fatal("Failed to open values storage: %s", err)
+		return
+	}
+	defer storage.Close()
+
 	// start polling sensors
 	if err := sensors.Run(conf); err != nil {
 		slog.Fatal("Failed to start sensors poller: %s", err)
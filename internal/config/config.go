@@ -13,8 +13,16 @@ import (
 var configFile string
 
 type Server struct {
-	Listen    string `json:"listen"`    // listen to http requests here
-	Resources string `json:"resources"` // path to resources dir
+	Listen      string `json:"listen"`                 // listen to http requests here
+	Resources   string `json:"resources"`              // path to resources dir
+	ExecSensors bool   `json:"exec sensors,omitempty"` // allow to add "exec" sensors and change their commands via web page
+}
+
+type Storage struct {
+	Dir             string `json:"dir"`                     // values storage dir, storage is disabled if empty
+	RawRetention    int    `json:"raw retention,omitempty"` // how long to keep raw values, in hours
+	MinuteRetention int    `json:"1m retention,omitempty"`  // how long to keep 1 minute values, in hours
+	HourRetention   int    `json:"1h retention,omitempty"`  // how long to keep 1 hour values, in hours
 }
 
 type Group struct {
@@ -40,9 +48,14 @@ type Column struct {
 }
 
 type Config struct {
-	Server      *Server   `json:"server"`       // server config
-	SysinfoPoll int       `json:"sysinfo poll"` // sysinfo ticket poll interval
-	Columns     []*Column `json:"columns"`      // sensors config: columns->groups->sensors
+	Server            *Server   `json:"server"`                       // server config
+	SysinfoPoll       int       `json:"sysinfo poll"`                 // sysinfo ticket poll interval
+	ChipsFile         string    `json:"chips file,omitempty"`         // user chips knowledge base (json), extends built-in one
+	LmSensorsConf     string    `json:"lm-sensors config,omitempty"`  // lm-sensors config file or dir, default system ones if empty
+	HistoryDuration   int       `json:"history duration,omitempty"`   // sensors history length, in seconds, 0 is default, -1 disables it
+	HistoryResolution int       `json:"history resolution,omitempty"` // sensors history resolution, in seconds, 0 is default
+	Storage           *Storage  `json:"storage,omitempty"`            // sensors values storage
+	Columns           []*Column `json:"columns"`                      // sensors config: columns->groups->sensors
 }
 
 func (c *Config) Load(path string) error {
@@ -65,6 +78,11 @@ func (c *Config) Load(path string) error {
 func (c *Config) ImportServerData(c2 *Config) {
 	c.Server = c2.Server
 	c.SysinfoPoll = c2.SysinfoPoll
+	c.ChipsFile = c2.ChipsFile
+	c.LmSensorsConf = c2.LmSensorsConf
+	c.HistoryDuration = c2.HistoryDuration
+	c.HistoryResolution = c2.HistoryResolution
+	c.Storage = c2.Storage
 }
 
 func (c *Config) Save() error {
@@ -127,6 +145,22 @@ func (c *Config) FindGroupById(id string) (int, int, *Group) {
 	return 0, 0, nil
 }
 
+// find the group which has sensors of the device
+func (c *Config) FindGroupByDevice(device string) *Group {
+
+	for _, col := range c.Columns {
+		for _, grp := range col.Groups {
+			for _, s := range grp.Sensors {
+				if s.Options.Device == device {
+					return grp
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 func (c *Config) RemoveGroup(g *Group) {
 	for _, col := range c.Columns {
 		for gi, grp := range col.Groups {
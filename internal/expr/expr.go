@@ -0,0 +1,416 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// known functions
+var functions = map[string]struct {
+	single bool // takes exactly one argument
+	fn     func(args []float64) float64
+}{
+	"min": {false, func(args []float64) float64 {
+		v := args[0]
+		for _, a := range args[1:] {
+			v = math.Min(v, a)
+		}
+		return v
+	}},
+	"max": {false, func(args []float64) float64 {
+		v := args[0]
+		for _, a := range args[1:] {
+			v = math.Max(v, a)
+		}
+		return v
+	}},
+	"sum": {false, func(args []float64) float64 {
+		v := 0.0
+		for _, a := range args {
+			v += a
+		}
+		return v
+	}},
+	"avg": {false, func(args []float64) float64 {
+		v := 0.0
+		for _, a := range args {
+			v += a
+		}
+		return v / float64(len(args))
+	}},
+	"abs": {true, func(args []float64) float64 {
+		return math.Abs(args[0])
+	}},
+}
+
+// variables range, i.e. "core0..7"
+var rangeRe = regexp.MustCompile(`^(.*?)([0-9]+)\.\.([0-9]+)$`)
+
+// max variables in a range
+const MAX_RANGE = 1024
+
+// arithmetic expression in lm-sensors "compute" syntax:
+// numbers, '@' (the value), variable names, + - * / and parentheses,
+// prefix '^' is exp() and prefix '`' is ln(), i.e. "(@ * 2) - 0.5",
+// plus functions min, max, avg, sum and abs, their arguments may be
+// ranges of variables, i.e. "max(core0..7)" is "max(core0, core1, ..., core7)"
+type Expr struct {
+	src  string
+	root node
+	vars []string // referenced variable names
+}
+
+// expression values
+type Vars map[string]float64
+
+// compiled expression tree node
+type node func(at float64, vars Vars) (float64, error)
+
+// expression parser state
+type parser struct {
+	src  string
+	pos  int
+	vars []string
+}
+
+// parse expression
+func Parse(src string) (*Expr, error) {
+
+	p := &parser{src: src}
+
+	root, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.skipSpaces(); p.pos < len(p.src) {
+		return nil, p.errorf("unexpected '%c'", p.src[p.pos])
+	}
+
+	return &Expr{src: src, root: root, vars: p.vars}, nil
+}
+
+// evaluate expression using '@' value and variables
+func (e *Expr) Eval(at float64, vars Vars) (float64, error) {
+	return e.root(at, vars)
+}
+
+// names of variables used in expression
+func (e *Expr) Vars() []string {
+	return e.vars
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, args...), p.pos+1)
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// return next non-space char, 0 at the end
+func (p *parser) peek() byte {
+	if p.skipSpaces(); p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// sum := product { ('+' | '-') product }
+func (p *parser) parseSum() (node, error) {
+
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+
+		left = binary(op, left, right)
+	}
+}
+
+// product := unary { ('*' | '/') unary }
+func (p *parser) parseProduct() (node, error) {
+
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = binary(op, left, right)
+	}
+}
+
+// unary := ('-' | '^' | '`') unary | primary
+func (p *parser) parseUnary() (node, error) {
+
+	op := p.peek()
+
+	var fn func(float64) float64
+	switch op {
+	case '-':
+		fn = func(v float64) float64 { return -v }
+	case '^':
+		fn = math.Exp
+	case '`':
+		fn = math.Log
+	default:
+		return p.parsePrimary()
+	}
+
+	p.pos++
+
+	arg, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(at float64, vars Vars) (float64, error) {
+		v, err := arg(at, vars)
+		if err != nil {
+			return 0.0, err
+		}
+		return fn(v), nil
+	}, nil
+}
+
+// primary := number | '@' | name | call | '(' sum ')'
+func (p *parser) parsePrimary() (node, error) {
+
+	c := p.peek()
+
+	switch {
+
+	case c == 0:
+		return nil, p.errorf("unexpected end of expression")
+
+	case c == '@':
+		p.pos++
+		return func(at float64, _ Vars) (float64, error) { return at, nil }, nil
+
+	case c == '(':
+		p.pos++
+		n, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, p.errorf("missing ')'")
+		}
+		p.pos++
+		return n, nil
+
+	case c == '.' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] == '.' || (p.src[p.pos] >= '0' && p.src[p.pos] <= '9')) {
+			p.pos++
+		}
+		num := p.src[start:p.pos]
+		value, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number '%s'", num)
+		}
+		return func(float64, Vars) (float64, error) { return value, nil }, nil
+
+	case isNameChar(c, true):
+		start := p.pos
+		name := p.parseName()
+		if p.peek() == '(' {
+			return p.parseCall(name)
+		}
+		if strings.Contains(name, "..") {
+			p.pos = start
+			if m := rangeRe.FindStringSubmatch(name); m != nil && !strings.Contains(m[1], "..") {
+				return nil, p.errorf("range '%s' is allowed in function arguments only", name)
+			}
+			return nil, p.errorf("invalid range '%s'", name)
+		}
+		p.addVar(name)
+		return variable(name), nil
+
+	}
+
+	return nil, p.errorf("unexpected '%c'", c)
+}
+
+func (p *parser) parseName() string {
+	start := p.pos
+	for p.pos < len(p.src) && isNameChar(p.src[p.pos], false) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// call := name '(' arg { ',' arg } ')', arg := range | sum
+func (p *parser) parseCall(name string) (node, error) {
+
+	f, ok := functions[name]
+	if !ok {
+		return nil, p.errorf("unknown function '%s'", name)
+	}
+
+	p.pos++ // '('
+
+	args := make([]node, 0)
+
+	for {
+
+		if names, err := p.parseRange(); err != nil {
+			return nil, err
+		} else if names != nil {
+			for _, name := range names {
+				p.addVar(name)
+				args = append(args, variable(name))
+			}
+		} else if arg, err := p.parseSum(); err != nil {
+			return nil, err
+		} else {
+			args = append(args, arg)
+		}
+
+		if c := p.peek(); c == ',' {
+			p.pos++
+		} else if c == ')' {
+			p.pos++
+			break
+		} else {
+			return nil, p.errorf("missing ')'")
+		}
+	}
+
+	if f.single && len(args) != 1 {
+		return nil, p.errorf("function '%s' takes one argument", name)
+	}
+
+	return func(at float64, vars Vars) (float64, error) {
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			v, err := arg(at, vars)
+			if err != nil {
+				return 0.0, err
+			}
+			values[i] = v
+		}
+		return f.fn(values), nil
+	}, nil
+}
+
+// parse variables range function argument, i.e. "core0..7", return nil if it's not a range
+func (p *parser) parseRange() ([]string, error) {
+
+	start := p.pos
+
+	if !isNameChar(p.peek(), true) {
+		return nil, nil
+	}
+
+	m := rangeRe.FindStringSubmatch(p.parseName())
+	if c := p.peek(); m == nil || (c != ',' && c != ')') {
+		p.pos = start
+		return nil, nil
+	}
+
+	from, _ := strconv.Atoi(m[2])
+	to, _ := strconv.Atoi(m[3])
+	if from > to || to-from >= MAX_RANGE || strings.Contains(m[1], "..") {
+		p.pos = start
+		return nil, p.errorf("invalid range '%s'", m[0])
+	}
+
+	names := make([]string, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		names = append(names, m[1]+strconv.Itoa(i))
+	}
+
+	return names, nil
+}
+
+func (p *parser) addVar(name string) {
+	for _, v := range p.vars {
+		if v == name {
+			return
+		}
+	}
+	p.vars = append(p.vars, name)
+}
+
+// variable names look like "in3", "temp1_input" or "cpu.total"
+func isNameChar(c byte, first bool) bool {
+	if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+		return true
+	}
+	return !first && (c == '.' || (c >= '0' && c <= '9'))
+}
+
+func variable(name string) node {
+	return func(_ float64, vars Vars) (float64, error) {
+		if value, ok := vars[name]; ok {
+			return value, nil
+		}
+		return 0.0, fmt.Errorf("unknown variable '%s'", name)
+	}
+}
+
+func binary(op byte, left, right node) node {
+	return func(at float64, vars Vars) (float64, error) {
+
+		l, err := left(at, vars)
+		if err != nil {
+			return 0.0, err
+		}
+
+		r, err := right(at, vars)
+		if err != nil {
+			return 0.0, err
+		}
+
+		switch op {
+		case '+':
+			return l + r, nil
+		case '-':
+			return l - r, nil
+		case '*':
+			return l * r, nil
+		default:
+			if r == 0 {
+				return 0.0, errors.New("division by zero")
+			}
+			return l / r, nil
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package expr
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+
+	vars := Vars{"core0": 40, "core1": 55, "core2": 47, "in3": 1.5, "cpu.total": 10}
+
+	tests := []struct {
+		src  string
+		at   float64
+		want float64
+	}{
+		{"42", 0, 42},
+		{".5", 0, 0.5},
+		{"@", 3, 3},
+		{"1 + 2 * 3", 0, 7},
+		{"(1 + 2) * 3", 0, 9},
+		{"8 - 2 - 1", 0, 5},
+		{"8 / 2 / 2", 0, 2},
+		{"10 / 4", 0, 2.5},
+		{"-2 * 3", 0, -6},
+		{"2 - -3", 0, 5},
+		{"--@", 4, 4},
+		{"-@ + 1", 4, -3},
+		{"^0", 0, 1},
+		{"`1", 0, 0},
+		{"^`2", 0, 2},
+		{"`^@ * 2", 3, 6},
+		{"((6.8/10)+1)*@", 5, 8.4},
+		{"(1+232/56)*@ - 4.096*232/56", 0, -4.096 * 232 / 56},
+		{"in3 * 2", 0, 3},
+		{"cpu.total + 1", 0, 11},
+		{"max(core0..2)", 0, 55},
+		{"min(core0..2)", 0, 40},
+		{"sum(core0..1, 5)", 0, 100},
+		{"avg(core0..2)", 0, 47.333333333333336},
+		{"max(core0 , core2 * 2)", 0, 94},
+		{"abs(-@)", 7, 7},
+		{"max(core1..1)", 0, 55},
+		{" 1 +\t2 ", 0, 3},
+	}
+
+	for _, tt := range tests {
+
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %s", tt.src, err)
+			continue
+		}
+
+		got, err := e.Eval(tt.at, vars)
+		if err != nil {
+			t.Errorf("Eval(%q) failed: %s", tt.src, err)
+			continue
+		}
+
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Eval(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestVars(t *testing.T) {
+
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"@ * 2", nil},
+		{"a + b * a", []string{"a", "b"}},
+		{"max(core0..2) + core1 + x", []string{"core0", "core1", "core2", "x"}},
+	}
+
+	for _, tt := range tests {
+
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %s", tt.src, err)
+			continue
+		}
+
+		if got := e.Vars(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q).Vars() = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	tests := []struct {
+		src  string
+		want string // error message part
+	}{
+		{"", "unexpected end"},
+		{"1 +", "unexpected end"},
+		{"(1 + 2", "missing ')'"},
+		{"1 2", "unexpected '2'"},
+		{"1 + )", "unexpected ')'"},
+		{"1.2.3", "invalid number"},
+		{"foo(1)", "unknown function 'foo'"},
+		{"abs(1, 2)", "takes one argument"},
+		{"max(1, 2", "missing ')'"},
+		{"core0..7", "allowed in function arguments only"},
+		{"core0..7 + 1", "allowed in function arguments only"},
+		{"max(core0..7 + 1)", "allowed in function arguments only"},
+		{"max(a..b)", "invalid range 'a..b'"},
+		{"max(core..7)", "invalid range"},
+		{"max(core0..)", "invalid range"},
+		{"max(core0..7..9)", "invalid range"},
+		{"max(core7..0)", "invalid range"},
+		{"max(core0..5000)", "invalid range"},
+		{"a..b", "invalid range"},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse(tt.src); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", tt.src)
+		} else if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) error is %q, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"1 / (@ - 1)", "division by zero"},
+		{"x + 1", "unknown variable 'x'"},
+		{"max(core0..1)", "unknown variable 'core1'"},
+	}
+
+	for _, tt := range tests {
+
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %s", tt.src, err)
+			continue
+		}
+
+		if _, err := e.Eval(1, Vars{"core0": 1}); err == nil {
+			t.Errorf("Eval(%q) succeeded, want error", tt.src)
+		} else if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Eval(%q) error is %q, want %q", tt.src, err, tt.want)
+		}
+	}
+}
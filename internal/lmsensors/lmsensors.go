@@ -0,0 +1,293 @@
+package lmsensors
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/danwakefield/fnmatch"
+)
+
+// default lm-sensors config files, the first existing one is used, plus all files of SENSORS_D
+var defaultFiles = []string{
+	"/etc/sensors3.conf",
+	"/etc/sensors.conf",
+}
+
+const (
+	SENSORS_D = "/etc/sensors.d"
+)
+
+// lm-sensors "chip" section
+type Chip struct {
+	Names    []string          // chip name patterns, i.e. "it87-*" or "nct6775-isa-0290"
+	Labels   map[string]string // feature labels, i.e. "in0" -> "Vcore"
+	Computes map[string]string // raw to real value expressions, i.e. "in4" -> "((30/10)+1)*@"
+	Sets     map[string]string // feature attributes values, i.e. "in0_min" -> "1.1 * 0.95"
+	Ignores  []string          // ignored features
+}
+
+// parsed lm-sensors config files
+type Config struct {
+	Chips []*Chip
+}
+
+// everything known about a chip feature, later statements override earlier ones
+type Feature struct {
+	Label   string
+	Compute string
+	Sets    map[string]string // attribute, i.e. "min" -> value expression
+	Ignored bool
+}
+
+// load default config files, missed ones are not an error
+func LoadDefault() (*Config, error) {
+
+	conf := new(Config)
+
+	for _, path := range defaultFiles {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := conf.Load(path); err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	files, _ := filepath.Glob(SENSORS_D + "/*")
+	sort.Strings(files)
+
+	for _, path := range files {
+		// sensors.d files are read in alphabetical order, hidden ones are skipped
+		if strings.HasPrefix(filepath.Base(path), ".") {
+			continue
+		}
+		if st, err := os.Stat(path); err != nil || !st.Mode().IsRegular() {
+			continue
+		}
+		if err := conf.Load(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return conf, nil
+}
+
+// load config file or all files of a dir, add its chips
+func (c *Config) Load(path string) error {
+
+	if st, err := os.Stat(path); err != nil {
+		return err
+	} else if st.IsDir() {
+		files, _ := filepath.Glob(path + "/*")
+		sort.Strings(files)
+		for _, file := range files {
+			if err := c.Load(file); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	fp, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	return c.Parse(fp, path)
+}
+
+// parse config data, add its chips
+func (c *Config) Parse(r io.Reader, name string) error {
+
+	var chip *Chip
+
+	lineNo := 0
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+
+		lineNo++
+		line := scanner.Text()
+
+		// backslash continues the line
+		for strings.HasSuffix(line, "\\") && scanner.Scan() {
+			lineNo++
+			line = strings.TrimSuffix(line, "\\") + " " + scanner.Text()
+		}
+
+		words, err := splitLine(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s", name, lineNo, err)
+		} else if len(words) == 0 {
+			continue
+		}
+
+		stmt, args := words[0], words[1:]
+
+		errorf := func(format string, a ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s: %s", name, lineNo, stmt, fmt.Sprintf(format, a...))
+		}
+
+		switch stmt {
+
+		case "bus":
+			// bus numbers mapping, i2c buses names are not used
+
+		case "chip":
+			if len(args) == 0 {
+				return errorf("chip name expected")
+			}
+			chip = &Chip{
+				Names:    args,
+				Labels:   make(map[string]string),
+				Computes: make(map[string]string),
+				Sets:     make(map[string]string),
+			}
+			c.Chips = append(c.Chips, chip)
+
+		case "label", "compute", "set", "ignore":
+
+			if chip == nil {
+				return errorf("no chip defined")
+			}
+
+			if len(args) == 0 {
+				return errorf("feature name expected")
+			}
+
+			feature := args[0]
+			rest := strings.TrimSpace(strings.Join(args[1:], " "))
+
+			switch stmt {
+			case "label":
+				if len(args) != 2 {
+					return errorf("one label expected")
+				}
+				chip.Labels[feature] = args[1]
+			case "compute":
+				// only "from raw" expression is used, "to raw" is for limits setting
+				from, _, _ := strings.Cut(rest, ",")
+				if from = strings.TrimSpace(from); from == "" {
+					return errorf("expression expected")
+				}
+				chip.Computes[feature] = from
+			case "set":
+				if rest == "" {
+					return errorf("expression expected")
+				}
+				chip.Sets[feature] = rest
+			case "ignore":
+				chip.Ignores = append(chip.Ignores, feature)
+			}
+
+		default:
+			return fmt.Errorf("%s:%d: unknown statement '%s'", name, lineNo, stmt)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// split config line into words, quoted strings are single words, comments are dropped
+func splitLine(line string) ([]string, error) {
+
+	words := make([]string, 0)
+
+	var word strings.Builder
+	inWord, quoted := false, false
+
+	flush := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+
+	for i := 0; i < len(line); i++ {
+
+		c := line[i]
+
+		switch {
+		case quoted && c == '\\' && i+1 < len(line):
+			i++
+			word.WriteByte(line[i])
+		case quoted && c == '"':
+			quoted = false
+			flush()
+		case quoted:
+			word.WriteByte(c)
+		case c == '"':
+			flush()
+			quoted, inWord = true, true
+		case c == '#':
+			flush()
+			return words, nil
+		case c == ' ' || c == '\t' || c == '\r':
+			flush()
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unterminated string")
+	}
+
+	flush()
+
+	return words, nil
+}
+
+// does chip name match any of chip patterns?
+func (chip *Chip) Match(name string) bool {
+	for _, pattern := range chip.Names {
+		if fnmatch.Match(pattern, name, 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// collect feature statements of all matching chips
+func (c *Config) Feature(chipName string, feature string) *Feature {
+
+	f := &Feature{Sets: make(map[string]string)}
+
+	for _, chip := range c.Chips {
+
+		if !chip.Match(chipName) {
+			continue
+		}
+
+		if label, ok := chip.Labels[feature]; ok {
+			f.Label = label
+		}
+
+		if compute, ok := chip.Computes[feature]; ok {
+			f.Compute = compute
+		}
+
+		for attr, value := range chip.Sets {
+			if strings.HasPrefix(attr, feature+"_") {
+				f.Sets[strings.TrimPrefix(attr, feature+"_")] = value
+			}
+		}
+
+		for _, ign := range chip.Ignores {
+			if ign == feature {
+				f.Ignored = true
+			}
+		}
+	}
+
+	return f
+}
@@ -0,0 +1,97 @@
+package lmsensors
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFeature(t *testing.T) {
+
+	conf := new(Config)
+	if err := conf.Load("testdata/sensors.conf"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		chip    string
+		feature string
+		want    Feature
+	}{
+		{"it87-isa-0228", "in0", Feature{Label: "Vcore 1", Sets: map[string]string{"min": "1.1 * 0.95", "max": "1.1 * 1.05"}}},
+		{"it87-isa-0228", "in3", Feature{Label: "+5V", Compute: "((6.8/10)+1)*@", Sets: map[string]string{}}},
+		{"it87-isa-0228", "fan4", Feature{Sets: map[string]string{}, Ignored: true}},
+		{"it8712-isa-0228", "in1", Feature{Sets: map[string]string{}}},
+		{"it8718-isa-0290", "in0", Feature{Sets: map[string]string{}}},
+		{"it87-isa-0290", "in3", Feature{Label: `+5V "main"`, Compute: "((6.8/10)+1)*@", Sets: map[string]string{}}},
+		{"it87-isa-0290", "in4", Feature{Label: "+12V", Compute: "(1+232/56)*@ - 4.096*232/56", Sets: map[string]string{}}},
+		{"it87-isa-0290", "temp1", Feature{Label: "CPU Temp", Sets: map[string]string{"max": "60"}}},
+		{"it87-isa-0290", "in8", Feature{Sets: map[string]string{}, Ignored: true}},
+		{"k10temp-pci-00c3", "temp1", Feature{Label: "Tctl", Sets: map[string]string{}}},
+		{"nct6775-isa-0290", "in0", Feature{Sets: map[string]string{}}},
+	}
+
+	for _, tt := range tests {
+		if got := conf.Feature(tt.chip, tt.feature); !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("Feature(%q, %q) = %+v, want %+v", tt.chip, tt.feature, *got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	tests := []struct {
+		data string
+		want string // error message part
+	}{
+		{"label in0 Vcore", "test:1: label: no chip defined"},
+		{"chip", "chip name expected"},
+		{"chip \"it87-*\"\n\nlabel in0 \"Vcore", "test:3: unterminated string"},
+		{"chip \"it87-*\"\nlabel in0", "test:2: label: one label expected"},
+		{"chip \"it87-*\"\nlabel", "feature name expected"},
+		{"chip \"it87-*\"\ncompute in0 , @*2", "compute: expression expected"},
+		{"chip \"it87-*\"\nset in0_min", "set: expression expected"},
+		{"chip \"it87-*\"\nfoo in0", "test:2: unknown statement 'foo'"},
+	}
+
+	for _, tt := range tests {
+		if err := new(Config).Parse(strings.NewReader(tt.data), "test"); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", tt.data)
+		} else if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) error is %q, want %q", tt.data, err, tt.want)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+
+	dir := t.TempDir()
+
+	// files are read in alphabetical order, later ones override earlier
+	files := map[string]string{
+		"10-board.conf": "chip \"nct6775-*\"\n label in0 Vcore\n label in1 +12V\n",
+		"20-local.conf": "chip \"nct6775-*\"\n label in1 \"12V rail\"\n ignore fan6\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := new(Config)
+	if err := conf.Load(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if f := conf.Feature("nct6775-isa-0290", "in0"); f.Label != "Vcore" {
+		t.Errorf("in0 label is %q, want %q", f.Label, "Vcore")
+	}
+	if f := conf.Feature("nct6775-isa-0290", "in1"); f.Label != "12V rail" {
+		t.Errorf("in1 label is %q, want %q", f.Label, "12V rail")
+	}
+	if f := conf.Feature("nct6775-isa-0290", "fan6"); !f.Ignored {
+		t.Errorf("fan6 is not ignored")
+	}
+}
@@ -0,0 +1,218 @@
+package sensors
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/danwakefield/fnmatch"
+	"github.com/maxb-odessa/nonsens/internal/expr"
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+
+	"github.com/maxb-odessa/slog"
+)
+
+// built-in chips knowledge base
+//
+//go:embed chips.json
+var builtinChips []byte
+
+// known chip input, keyed by input prefix, i.e. "in3" or "temp1"
+type chipInput struct {
+	Label   string `json:"label,omitempty"`   // visible sensor name
+	Compute string `json:"compute,omitempty"` // value formula, '@' is the value, i.e. "((30/10)+1)*@"
+	Units   string `json:"units,omitempty"`   // value units
+}
+
+// known chip, keyed by space separated hwmon names (patterns allowed), i.e. "nct6775 nct6779" or "it87*"
+type chipInfo struct {
+	Inputs map[string]*chipInput `json:"inputs,omitempty"` // known inputs
+	Ignore []string              `json:"ignore,omitempty"` // inputs to skip on scan
+}
+
+var chips struct {
+	sync.Mutex
+	db map[string]*chipInfo
+}
+
+// load built-in chips knowledge base and user one on top of it
+func LoadChips(path string) error {
+
+	chips.Lock()
+	defer chips.Unlock()
+
+	chips.db = make(map[string]*chipInfo)
+
+	if err := mergeChips(builtinChips); err != nil {
+		slog.Err("Broken built-in chips data: %s", err)
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(os.ExpandEnv(path))
+	if err != nil {
+		return err
+	}
+
+	if err := mergeChips(data); err != nil {
+		return err
+	}
+
+	slog.Info("Loaded chips data from '%s'", path)
+
+	return nil
+}
+
+// add chips data, known chip inputs are replaced
+func mergeChips(data []byte) error {
+
+	db := make(map[string]*chipInfo)
+	if err := json.Unmarshal(data, &db); err != nil {
+		return err
+	}
+
+	for names, info := range db {
+		addChip(names, info)
+	}
+
+	return nil
+}
+
+func addChip(names string, info *chipInfo) {
+
+	for _, name := range strings.Fields(names) {
+
+		chip, ok := chips.db[name]
+		if !ok {
+			chip = &chipInfo{Inputs: make(map[string]*chipInput)}
+			chips.db[name] = chip
+		}
+
+		for in, ci := range info.Inputs {
+			chip.Inputs[in] = ci
+		}
+
+		chip.Ignore = append(chip.Ignore, info.Ignore...)
+	}
+}
+
+// find chip data by hwmon name, exact name match beats patterns
+func findChip(name string) *chipInfo {
+
+	chips.Lock()
+	defer chips.Unlock()
+
+	if chips.db == nil {
+		return nil
+	}
+
+	if chip, ok := chips.db[name]; ok {
+		return chip
+	}
+
+	patterns := make([]string, 0)
+	for pattern := range chips.db {
+		if fnmatch.Match(pattern, name, 0) {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	sort.Strings(patterns)
+
+	return chips.db[patterns[0]]
+}
+
+// is chip input ignored? input is its prefix or full name, i.e. "in3" or "in3_input"
+func (chip *chipInfo) ignored(input string) bool {
+	prefix := strings.Split(input, "_")[0]
+	for _, ign := range chip.Ignore {
+		if ign == input || ign == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// apply known chip input options to scanned sensor
+func (chip *chipInfo) apply(sens *sensor.Sensor) {
+
+	ci, ok := chip.Inputs[strings.Split(sens.Options.Input, "_")[0]]
+	if !ok {
+		return
+	}
+
+	if ci.Label != "" {
+		sens.Widget.Name = ci.Label
+	}
+
+	if ci.Units != "" {
+		sens.Widget.Units = ci.Units
+	}
+
+	if ci.Compute != "" {
+		if err := setCompute(sens, ci.Compute); err != nil {
+			slog.Warn("Invalid compute expression '%s' for sensor '%s/%s': %s", ci.Compute, sens.Options.Device, sens.Options.Input, err)
+		}
+	}
+}
+
+// parse sensor compute expression, sensor value is its only input
+func parseCompute(src string) (*expr.Expr, error) {
+
+	compute, err := expr.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(compute.Vars()) > 0 {
+		return nil, fmt.Errorf("other features are not supported")
+	}
+
+	return compute, nil
+}
+
+// set sensor compute expression and apply it to sensor limits which are raw values too
+func setCompute(sens *sensor.Sensor, src string) error {
+
+	compute, err := parseCompute(src)
+	if err != nil {
+		return err
+	}
+
+	sens.Options.Compute = src
+
+	calc := func(value float64) float64 {
+		if v, err := compute.Eval(value, nil); err == nil {
+			return v
+		}
+		return value
+	}
+
+	if sens.Options.Max > sens.Options.Min {
+		sens.Options.Min = calc(sens.Options.Min)
+		sens.Options.Max = calc(sens.Options.Max)
+		if sens.Options.Min > sens.Options.Max {
+			sens.Options.Min, sens.Options.Max = sens.Options.Max, sens.Options.Min
+		}
+	}
+
+	for name, value := range sens.Options.Thresholds {
+		sens.Options.Thresholds[name] = calc(value)
+	}
+
+	if len(sens.Options.Thresholds) > 0 {
+		guessThresholdOptions(sens)
+	}
+
+	return nil
+}
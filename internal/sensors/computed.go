@@ -0,0 +1,215 @@
+package sensors
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/maxb-odessa/nonsens/internal/expr"
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+
+	"github.com/maxb-odessa/slog"
+)
+
+// computed sensor source: evaluates expression of other sensors values referred by their aliases,
+// it is not polled but updated whenever any of its inputs is updated
+type computedSource struct {
+	sens *sensor.Sensor // sensor this source belongs to
+	expr *expr.Expr     // parsed expression
+}
+
+// aliased sensors values and computed sensors depending on them
+var computed = struct {
+	sync.Mutex
+	aliases map[*sensor.Sensor]string  // set up sensors aliases
+	values  map[*sensor.Sensor]float64 // aliased sensors last values
+	sources map[*computedSource]bool   // set up computed sources
+}{
+	aliases: make(map[*sensor.Sensor]string),
+	values:  make(map[*sensor.Sensor]float64),
+	sources: make(map[*computedSource]bool),
+}
+
+// register sensor alias, the first sensor set up with the alias owns it
+func setAlias(sens *sensor.Sensor) {
+
+	alias := sens.Options.Alias
+	if alias == "" {
+		return
+	}
+
+	computed.Lock()
+	defer computed.Unlock()
+
+	if aliasOwner(alias) != nil {
+		slog.Warn("Alias '%s' of sensor '%s/%s' is already used by another sensor, ignoring it", alias, sens.Options.Device, sens.Options.Input)
+		return
+	}
+
+	computed.aliases[sens] = alias
+}
+
+// unregister sensor alias and drop its value, sensors depending on it are updated
+func forgetAlias(sens *sensor.Sensor) {
+
+	computed.Lock()
+
+	alias, ok := computed.aliases[sens]
+	if !ok {
+		computed.Unlock()
+		return
+	}
+
+	delete(computed.aliases, sens)
+	delete(computed.values, sens)
+
+	dependents := aliasDependents(alias, sens)
+
+	computed.Unlock()
+
+	for _, dep := range dependents {
+		dep.Trigger()
+	}
+}
+
+// sensor the alias is registered for, computed must be locked
+func aliasOwner(alias string) *sensor.Sensor {
+	for sens, a := range computed.aliases {
+		if a == alias {
+			return sens
+		}
+	}
+	return nil
+}
+
+// sensors computed of the alias value, except the sensor itself, computed must be locked
+func aliasDependents(alias string, sens *sensor.Sensor) []*sensor.Sensor {
+
+	dependents := make([]*sensor.Sensor, 0)
+
+	for src := range computed.sources {
+		if src.sens != sens && src.dependsOn(alias) && !src.cyclic(computed.aliases[src.sens], make(map[*computedSource]bool)) {
+			dependents = append(dependents, src.sens)
+		}
+	}
+
+	return dependents
+}
+
+func (cs *computedSource) Setup(sens *sensor.Sensor) error {
+
+	if sens.Options.Expression == "" {
+		return fmt.Errorf("expression is not defined")
+	}
+
+	e, err := expr.Parse(sens.Options.Expression)
+	if err != nil {
+		return fmt.Errorf("invalid expression: %s", err)
+	}
+
+	cs.sens = sens
+	cs.expr = e
+
+	computed.Lock()
+	computed.sources[cs] = true
+	computed.Unlock()
+
+	return nil
+}
+
+func (cs *computedSource) Read() (float64, error) {
+
+	computed.Lock()
+
+	if cs.cyclic(computed.aliases[cs.sens], make(map[*computedSource]bool)) {
+		computed.Unlock()
+		return 0.0, fmt.Errorf("circular dependency")
+	}
+
+	vars := make(expr.Vars)
+	for _, name := range cs.expr.Vars() {
+		if value, ok := computed.values[aliasOwner(name)]; ok {
+			vars[name] = value
+		} else {
+			computed.Unlock()
+			return 0.0, fmt.Errorf("no value of '%s'", name)
+		}
+	}
+
+	computed.Unlock()
+
+	return cs.expr.Eval(0.0, vars)
+}
+
+func (cs *computedSource) Describe() string {
+	if cs.sens.Options.Alias != "" {
+		return "computed/" + cs.sens.Options.Alias
+	}
+	return "computed/" + cs.expr.String()
+}
+
+func (cs *computedSource) Close() {
+	computed.Lock()
+	delete(computed.sources, cs)
+	computed.Unlock()
+}
+
+func (cs *computedSource) Triggered() bool {
+	return true
+}
+
+// does the source expression refer to alias?
+func (cs *computedSource) dependsOn(alias string) bool {
+	for _, name := range cs.expr.Vars() {
+		if name == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// does the source depend on itself? computed must be locked
+func (cs *computedSource) cyclic(alias string, seen map[*computedSource]bool) bool {
+
+	if alias == "" {
+		return false
+	}
+
+	for src := range computed.sources {
+		srcAlias := computed.aliases[src.sens]
+		if srcAlias == "" || seen[src] || !cs.dependsOn(srcAlias) {
+			continue
+		}
+		if src.dependsOn(alias) {
+			return true
+		}
+		seen[src] = true
+		if src.cyclic(alias, seen) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// save aliased sensor value and update computed sensors depending on it
+func updateComputed(sens *sensor.Sensor, value float64, valid bool) {
+
+	var dependents []*sensor.Sensor
+
+	computed.Lock()
+
+	if alias, ok := computed.aliases[sens]; ok {
+		if valid {
+			computed.values[sens] = value
+		} else {
+			delete(computed.values, sens)
+		}
+		dependents = aliasDependents(alias, sens)
+	}
+
+	computed.Unlock()
+
+	for _, dep := range dependents {
+		dep.Trigger()
+	}
+}
@@ -0,0 +1,203 @@
+package sensors
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/maxb-odessa/nonsens/internal/config"
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+)
+
+const (
+	PROC_STAT_PATH = "/proc/stat"
+)
+
+// /proc/stat cpu line fields
+const (
+	cpuUser = iota
+	cpuNice
+	cpuSystem
+	cpuIdle
+	cpuIowait
+	cpuIrq
+	cpuSoftirq
+	cpuSteal
+	cpuFields // guest times are already accounted in user time
+)
+
+// cpu usage sensor source: computes cpu usage percents from consecutive /proc/stat samples
+type cpuSource struct {
+	cpu   string            // cpu line name, i.e. "cpu" (all cpus) or "cpu3"
+	input string            // usage kind: total, user, system or iowait
+	prev  [cpuFields]uint64 // previous sample
+	value float64           // last calculated value
+}
+
+func (cs *cpuSource) Setup(sens *sensor.Sensor) error {
+	var err error
+
+	if sens.Options.Device == "" {
+		sens.Options.Device = "cpu"
+	}
+
+	if sens.Options.Input == "" {
+		sens.Options.Input = "total"
+	}
+
+	switch sens.Options.Input {
+	case "total", "user", "system", "iowait":
+	default:
+		return fmt.Errorf("unknown cpu usage input '%s'", sens.Options.Input)
+	}
+
+	cs.cpu = sens.Options.Device
+	cs.input = sens.Options.Input
+
+	// initial sample, next read will calculate usage since now
+	cs.prev, err = readCpuStat(cs.cpu)
+
+	return err
+}
+
+func (cs *cpuSource) Read() (float64, error) {
+
+	curr, err := readCpuStat(cs.cpu)
+	if err != nil {
+		return 0.0, err
+	}
+
+	var delta [cpuFields]float64
+	var total float64
+	for i := range curr {
+		// counters may go backwards on cpu hotplug
+		if curr[i] >= cs.prev[i] {
+			delta[i] = float64(curr[i] - cs.prev[i])
+		}
+		total += delta[i]
+	}
+
+	cs.prev = curr
+
+	// too short poll interval, nothing changed since prev read
+	if total == 0.0 {
+		return cs.value, nil
+	}
+
+	switch cs.input {
+	case "user":
+		cs.value = delta[cpuUser] + delta[cpuNice]
+	case "system":
+		cs.value = delta[cpuSystem] + delta[cpuIrq] + delta[cpuSoftirq]
+	case "iowait":
+		cs.value = delta[cpuIowait]
+	default:
+		cs.value = total - delta[cpuIdle] - delta[cpuIowait]
+	}
+
+	cs.value = cs.value * 100.0 / total
+
+	return cs.value, nil
+}
+
+func (cs *cpuSource) Describe() string {
+	return cs.cpu + "/" + cs.input
+}
+
+func (cs *cpuSource) Close() {
+}
+
+// read /proc/stat line of the cpu
+func readCpuStat(cpu string) ([cpuFields]uint64, error) {
+	var stat [cpuFields]uint64
+
+	fp, err := os.Open(PROC_STAT_PATH)
+	if err != nil {
+		return stat, err
+	}
+	defer fp.Close()
+
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < cpuFields+1 || fields[0] != cpu {
+			continue
+		}
+		for i := range stat {
+			if stat[i], err = strconv.ParseUint(fields[i+1], 10, 64); err != nil {
+				return stat, fmt.Errorf("invalid '%s' stat: %s", cpu, err)
+			}
+		}
+		return stat, nil
+	}
+
+	return stat, fmt.Errorf("'%s' not found in '%s'", cpu, PROC_STAT_PATH)
+}
+
+// list all cpu lines names of /proc/stat
+func listCpus() ([]string, error) {
+
+	fp, err := os.Open(PROC_STAT_PATH)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	cpus := make([]string, 0)
+
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 && strings.HasPrefix(fields[0], "cpu") {
+			cpus = append(cpus, fields[0])
+		}
+	}
+
+	return cpus, scanner.Err()
+}
+
+// make cpu usage sensors: total usage split by kind and usage of every cpu
+func scanCpu(conf *config.Config) error {
+
+	cpus, err := listCpus()
+	if err != nil {
+		return err
+	}
+
+	group := new(config.Group)
+	group.SetName("CPU usage")
+
+	addSensor := func(cpu, input, name string) {
+		se := new(sensor.Sensor)
+		se.Prepare()
+		se.SetDefaults()
+
+		se.Options.Type = "cpu"
+		se.Options.Device = cpu
+		se.Options.Input = input
+		se.Options.Min = 0.0
+		se.Options.Max = 100.0
+		se.Widget.Name = name
+		se.Widget.Units = "%"
+
+		SetupSensor(se)
+
+		conf.AddSensor(se, group)
+	}
+
+	for _, cpu := range cpus {
+		if cpu == "cpu" {
+			addSensor(cpu, "total", "Total")
+			addSensor(cpu, "user", "User")
+			addSensor(cpu, "system", "System")
+			addSensor(cpu, "iowait", "IO wait")
+		} else {
+			addSensor(cpu, "total", "Core "+strings.TrimPrefix(cpu, "cpu"))
+		}
+	}
+
+	addScannedGroup(conf, group)
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package sensors
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxb-odessa/nonsens/internal/config"
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+)
+
+const (
+	DISKSTATS_PATH = "/proc/diskstats"
+	BLOCK_PATH     = "/sys/block"
+
+	SECTOR_SIZE = 512.0 // diskstats sectors are always 512 bytes
+)
+
+// /proc/diskstats fields, disk name is at index 2
+const (
+	diskReads          = 3
+	diskSectorsRead    = 5
+	diskWrites         = 7
+	diskSectorsWritten = 9
+	diskIoTicks        = 12
+)
+
+// disk i/o sensor source: converts /proc/diskstats counters into per second rates
+type diskSource struct {
+	disk  string      // disk name, i.e. sda
+	input string      // read_bytes, write_bytes, iops or util
+	rate  sensor.Rate // counter to rate converter
+}
+
+func (ds *diskSource) Setup(sens *sensor.Sensor) error {
+
+	if sens.Options.Device == "" || sens.Options.Input == "" {
+		return fmt.Errorf("device or input is not defined")
+	}
+
+	ds.disk = sens.Options.Device
+	ds.input = sens.Options.Input
+
+	switch ds.input {
+	case "read_bytes", "write_bytes", "iops", "util":
+	default:
+		return fmt.Errorf("unknown disk input '%s'", ds.input)
+	}
+
+	sens.Runtime.Dir = BLOCK_PATH + "/" + ds.disk + "/"
+
+	// rate is counted from the first read, the sensor is started a moment later
+	if _, err := ds.counter(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ds *diskSource) Read() (float64, error) {
+
+	value, err := ds.counter()
+	if err != nil {
+		return 0.0, err
+	}
+
+	rate, _ := ds.rate.Update(value, time.Now())
+
+	// need at least two counter values to calculate the rate
+	if !ds.rate.Ready() {
+		return 0.0, errors.New("collecting data")
+	}
+
+	// io ticks are milliseconds spent doing i/o, make it percents of a second
+	if ds.input == "util" {
+		rate = math.Min(rate/10.0, 100.0)
+	}
+
+	return rate, nil
+}
+
+func (ds *diskSource) Describe() string {
+	return ds.disk + "/" + ds.input
+}
+
+func (ds *diskSource) Close() {
+}
+
+// get disk counter related to sensor input
+func (ds *diskSource) counter() (float64, error) {
+
+	stat, err := readDiskStat(ds.disk)
+	if err != nil {
+		return 0.0, err
+	}
+
+	switch ds.input {
+	case "read_bytes":
+		return stat[diskSectorsRead] * SECTOR_SIZE, nil
+	case "write_bytes":
+		return stat[diskSectorsWritten] * SECTOR_SIZE, nil
+	case "iops":
+		return stat[diskReads] + stat[diskWrites], nil
+	default:
+		return stat[diskIoTicks], nil
+	}
+}
+
+// read /proc/diskstats line of the disk
+func readDiskStat(disk string) ([]float64, error) {
+
+	fp, err := os.Open(DISKSTATS_PATH)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) <= diskIoTicks || fields[2] != disk {
+			continue
+		}
+		stat := make([]float64, len(fields))
+		for i := 3; i < len(fields); i++ {
+			if stat[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+				return nil, fmt.Errorf("invalid '%s' stat: %s", disk, err)
+			}
+		}
+		return stat, nil
+	}
+
+	return nil, fmt.Errorf("disk '%s' not found in '%s'", disk, DISKSTATS_PATH)
+}
+
+// make "Disks" group with i/o sensors of all real disks
+func scanDisks(conf *config.Config) error {
+
+	dirs, err := os.ReadDir(BLOCK_PATH)
+	if err != nil {
+		return err
+	}
+
+	inputs := []struct {
+		input string
+		name  string
+	}{
+		{"read_bytes", "read"},
+		{"write_bytes", "write"},
+		{"iops", "IOPS"},
+		{"util", "util"},
+	}
+
+	group := new(config.Group)
+	group.SetName("Disks")
+
+	for _, dir := range dirs {
+
+		dirName := BLOCK_PATH + "/" + dir.Name() + "/"
+
+		// skip virtual (loop, ram, zram, dm) devices but md raids
+		if _, err := os.Stat(dirName + "device"); err != nil && !strings.HasPrefix(dir.Name(), "md") {
+			continue
+		}
+
+		// skip empty devices, i.e. card readers without a card
+		if size, err := readFloat(dirName + "size"); err != nil || size == 0 {
+			continue
+		}
+
+		for _, in := range inputs {
+
+			se := new(sensor.Sensor)
+			se.Prepare()
+			se.SetDefaults()
+
+			se.Options.Type = "disk"
+			se.Options.Device = dir.Name()
+			se.Options.Input = in.input
+			se.Widget.Name = dir.Name() + " " + in.name
+
+			switch in.input {
+			case "iops":
+				se.Widget.Fractions = 0
+				se.Widget.Units = "IO/s"
+			case "util":
+				se.Options.Max = 100.0
+				se.Widget.Units = "%"
+			default:
+				se.Options.Divider = 1024.0 * 1024.0
+				se.Widget.Fractions = 2
+				se.Widget.Units = "MiB/s"
+			}
+
+			SetupSensor(se)
+
+			conf.AddSensor(se, group)
+		}
+	}
+
+	addScannedGroup(conf, group)
+
+	return nil
+}
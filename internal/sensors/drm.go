@@ -0,0 +1,240 @@
+package sensors
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/maxb-odessa/nonsens/internal/config"
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+
+	"github.com/maxb-odessa/slog"
+)
+
+const (
+	DRM_PATH = "/sys/class/drm"
+)
+
+var (
+	drmCardRe  = regexp.MustCompile(`^card[0-9]+$`)
+	dpmLevelRe = regexp.MustCompile(`^\s*[0-9]+:\s*([0-9.]+)\s*[MmGg][Hh]z.*\*\s*$`)
+)
+
+// gpu sensor source: reads /sys/class/drm/cardX/ files,
+// card is identified by its pci address, same as hwmon device id
+type drmSource struct {
+	device string // pci address, i.e. 0000:09:00.0
+	input  string // input file name relative to card dir, i.e. device/gpu_busy_percent
+	path   string // full path to input file
+	dpm    bool   // input is pp_dpm_* clock levels list
+}
+
+func (ds *drmSource) Setup(sens *sensor.Sensor) error {
+
+	if sens.Options.Device == "" || sens.Options.Input == "" {
+		return fmt.Errorf("device or input is not defined")
+	}
+
+	ds.device = sens.Options.Device
+	ds.input = sens.Options.Input
+	ds.dpm = strings.HasPrefix(filepath.Base(ds.input), "pp_dpm_")
+
+	if dir := findDrmDir(ds.device); dir == "" {
+		return fmt.Errorf("drm card not found")
+	} else {
+		sens.Runtime.Dir = dir
+	}
+
+	ds.path = sens.Runtime.Dir + ds.input
+
+	return nil
+}
+
+func (ds *drmSource) Read() (float64, error) {
+	if ds.dpm {
+		return readDpmLevel(ds.path, false)
+	}
+	return readFloat(ds.path)
+}
+
+func (ds *drmSource) ReadText() (string, error) {
+	return readString(ds.path)
+}
+
+func (ds *drmSource) Describe() string {
+	return ds.device + "/" + ds.input
+}
+
+func (ds *drmSource) Close() {
+}
+
+// read current (marked with '*') or max clock level of amdgpu pp_dpm_* file, in MHz:
+// 0: 500Mhz
+// 1: 1800Mhz *
+func readDpmLevel(path string, max bool) (float64, error) {
+
+	fp, err := os.Open(path)
+	if err != nil {
+		return 0.0, err
+	}
+	defer fp.Close()
+
+	level := -1.0
+
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if max {
+			// any level will do, marked or not
+			line = strings.TrimSuffix(strings.TrimSpace(line), "*") + " *"
+		}
+		if m := dpmLevelRe.FindStringSubmatch(line); m != nil {
+			value, _ := strconv.ParseFloat(m[1], 64)
+			if strings.Contains(strings.ToLower(line), "ghz") {
+				value *= 1000.0
+			}
+			if !max {
+				return value, nil
+			} else if value > level {
+				level = value
+			}
+		}
+	}
+
+	if level < 0 {
+		return 0.0, fmt.Errorf("no clock level found in '%s'", path)
+	}
+
+	return level, nil
+}
+
+// find drm card dir by its pci address
+func findDrmDir(device string) string {
+
+	dirs, err := os.ReadDir(DRM_PATH)
+	if err != nil {
+		slog.Err("Scan of '%s' failed: %s", DRM_PATH, err)
+		return ""
+	}
+
+	for _, dir := range dirs {
+		if !drmCardRe.MatchString(dir.Name()) {
+			continue
+		}
+		dirName := DRM_PATH + "/" + dir.Name() + "/"
+		if dev, err := filepath.EvalSymlinks(dirName + "device"); err == nil && filepath.Base(dev) == device {
+			return dirName
+		}
+	}
+
+	return ""
+}
+
+// scan drm cards and add gpu sensors to the group of card hwmon sensors
+func scanDrm(conf *config.Config) error {
+
+	dirs, err := os.ReadDir(DRM_PATH)
+	if os.IsNotExist(err) {
+		return nil // no drm class at all
+	} else if err != nil {
+		return err
+	}
+
+	inputs := []struct {
+		input string // input file relative to card dir
+		name  string // visible name
+		max   string // file with max value, relative to card dir
+	}{
+		// amdgpu
+		{"device/gpu_busy_percent", "GPU busy", ""},
+		{"device/mem_busy_percent", "VRAM busy", ""},
+		{"device/mem_info_vram_used", "VRAM used", "device/mem_info_vram_total"},
+		{"device/pp_dpm_sclk", "GPU clock", "device/pp_dpm_sclk"},
+		{"device/pp_dpm_mclk", "VRAM clock", "device/pp_dpm_mclk"},
+		// i915
+		{"gt_act_freq_mhz", "GPU clock", "gt_RP0_freq_mhz"},
+		// xe
+		{"device/tile0/gt0/freq0/act_freq", "GPU clock", "device/tile0/gt0/freq0/rp0_freq"},
+	}
+
+	for _, dir := range dirs {
+
+		if !drmCardRe.MatchString(dir.Name()) {
+			continue
+		}
+
+		dirName := DRM_PATH + "/" + dir.Name() + "/"
+
+		dev, err := filepath.EvalSymlinks(dirName + "device")
+		if err != nil {
+			continue
+		}
+		device := filepath.Base(dev)
+
+		// attach to the card hwmon sensors group if any
+		group := conf.FindGroupByDevice(device)
+		newGroup := group == nil
+		if newGroup {
+			group = new(config.Group)
+			group.SetName(dir.Name())
+		}
+
+		for _, in := range inputs {
+
+			if _, err := os.Stat(dirName + in.input); err != nil {
+				continue
+			}
+
+			se := new(sensor.Sensor)
+			se.Prepare()
+			se.SetDefaults()
+
+			se.Options.Type = "drm"
+			se.Options.Device = device
+			se.Options.Input = in.input
+			se.Widget.Name = in.name
+
+			switch {
+			case strings.HasSuffix(in.input, "_percent"):
+				se.Options.Max = 100.0
+				se.Widget.Fractions = 0
+				se.Widget.Units = "%"
+			case strings.HasSuffix(in.input, "_used"):
+				se.Options.Divider = 1024.0 * 1024.0
+				se.Widget.Fractions = 0
+				se.Widget.Units = "MiB"
+			default:
+				se.Widget.Fractions = 0
+				se.Widget.Units = "MHz"
+			}
+
+			// guess max value
+			if in.max != "" {
+				var max float64
+				var err error
+				if strings.Contains(in.max, "pp_dpm_") {
+					max, err = readDpmLevel(dirName+in.max, true)
+				} else {
+					max, err = readFloat(dirName + in.max)
+				}
+				if err == nil && max > 0 {
+					se.Options.Max = max / se.Options.Divider
+				}
+			}
+
+			SetupSensor(se)
+
+			conf.AddSensor(se, group)
+		}
+
+		if newGroup {
+			addScannedGroup(conf, group)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package sensors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+)
+
+// how long to wait for command output to be closed after it is killed by timeout
+const EXEC_WAIT_DELAY = 100 * time.Millisecond
+
+// exec sensor source: runs a command and parses its stdout
+type execSource struct {
+	command string         // command to run
+	args    []string       // command args
+	timeout time.Duration  // max command run time
+	match   *regexp.Regexp // regex to extract value from stdout
+	path    []string       // json path to value in stdout
+}
+
+func (es *execSource) Setup(sens *sensor.Sensor) error {
+
+	if sens.Options.Command == "" {
+		return fmt.Errorf("command is not defined")
+	}
+
+	es.command = sens.Options.Command
+	es.args = sens.Options.Args
+
+	// run timeout defaults to poll interval
+	if sens.Options.Timeout > 0 {
+		es.timeout = time.Duration(sens.Options.Timeout) * time.Millisecond
+	} else if sens.Options.Poll > 0 {
+		es.timeout = time.Duration(sens.Options.Poll) * time.Millisecond
+	} else {
+		es.timeout = time.Second
+	}
+
+	if sens.Options.Match != "" {
+		if re, err := regexp.Compile(sens.Options.Match); err != nil {
+			return fmt.Errorf("invalid match regex: %s", err)
+		} else {
+			es.match = re
+		}
+	}
+
+	if sens.Options.JsonPath != "" {
+		es.path = strings.Split(sens.Options.JsonPath, ".")
+	}
+
+	return nil
+}
+
+func (es *execSource) Read() (float64, error) {
+
+	out, err := es.run()
+	if err != nil {
+		return 0.0, err
+	}
+
+	if es.path != nil {
+		return jsonValue(out, es.path)
+	}
+
+	s, err := es.extract(out)
+	if err != nil {
+		return 0.0, err
+	}
+
+	if value, err := strconv.ParseFloat(s, 64); err != nil {
+		return 0.0, fmt.Errorf("invalid value '%s'", s)
+	} else {
+		return value, nil
+	}
+}
+
+func (es *execSource) ReadText() (string, error) {
+
+	out, err := es.run()
+	if err != nil {
+		return "", err
+	}
+
+	if es.path != nil {
+		if obj, err := jsonLookup(out, es.path); err != nil {
+			return "", err
+		} else {
+			return fmt.Sprint(obj), nil
+		}
+	}
+
+	return es.extract(out)
+}
+
+// run the command and return its stdout
+func (es *execSource) run() ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	ctx, cancel := context.WithTimeout(context.Background(), es.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, es.command, es.args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// command children may keep its stdout open, don't wait for them once the command exited or is killed
+	cmd.WaitDelay = EXEC_WAIT_DELAY
+
+	if err := cmd.Run(); err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("timed out after %s", es.timeout)
+		}
+		// show the first line of stderr, it usually tells what's wrong
+		if msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n"); msg != "" {
+			return nil, fmt.Errorf("%s: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// extract value from command output using match regex if defined
+func (es *execSource) extract(out []byte) (string, error) {
+
+	s := strings.TrimSpace(string(out))
+
+	if es.match != nil {
+		m := es.match.FindStringSubmatch(s)
+		if m == nil {
+			return "", fmt.Errorf("output does not match '%s'", es.match)
+		}
+		// use 1-st subexpression if defined, whole match otherwise
+		s = m[0]
+		if len(m) > 1 {
+			s = m[1]
+		}
+	}
+
+	return strings.TrimSpace(s), nil
+}
+
+func (es *execSource) Describe() string {
+	return "exec/" + filepath.Base(es.command)
+}
+
+func (es *execSource) Close() {
+}
+
+// extract a number from json data by dot-separated path, i.e. "gpus.0.temp"
+func jsonValue(data []byte, path []string) (float64, error) {
+
+	obj, err := jsonLookup(data, path)
+	if err != nil {
+		return 0.0, err
+	}
+
+	switch v := obj.(type) {
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1.0, nil
+		}
+		return 0.0, nil
+	case string:
+		if value, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return value, nil
+		}
+	}
+
+	return 0.0, fmt.Errorf("json value '%v' is not a number", obj)
+}
+
+// find json data item by path
+func jsonLookup(data []byte, path []string) (interface{}, error) {
+	var obj interface{}
+
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("invalid json: %s", err)
+	}
+
+	for _, key := range path {
+		switch o := obj.(type) {
+		case map[string]interface{}:
+			if v, ok := o[key]; ok {
+				obj = v
+			} else {
+				return nil, fmt.Errorf("json key '%s' not found", key)
+			}
+		case []interface{}:
+			if idx, err := strconv.Atoi(key); err != nil || idx < 0 || idx >= len(o) {
+				return nil, fmt.Errorf("json index '%s' is invalid", key)
+			} else {
+				obj = o[idx]
+			}
+		default:
+			return nil, fmt.Errorf("json key '%s' not found", key)
+		}
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,87 @@
+package sensors
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+
+	"github.com/maxb-odessa/slog"
+)
+
+// file sensor source: reads any file by its full path, i.e.
+// /sys/devices/.../iio:device0/in_illuminance_raw
+// path may contain glob patterns (resolved at setup time) to survive
+// devices renumbering, i.e. /sys/class/hwmon/hwmon*/device/temp1_input
+// file sensors may read kernel attributes only: the value or its text is shown to anyone,
+// the rest of /proc is not allowed as it has processes environment and command lines
+var fileSourceDirs = []string{
+	"/sys/",
+	"/proc/sys/",
+}
+
+type fileSource struct {
+	pattern string // configured path, may be a glob pattern
+	path    string // resolved full path to input file
+}
+
+func (fs *fileSource) Setup(sens *sensor.Sensor) error {
+
+	if !filepath.IsAbs(sens.Options.Input) {
+		return fmt.Errorf("input is not a full path")
+	}
+
+	fs.pattern = sens.Options.Input
+
+	matches, err := filepath.Glob(fs.pattern)
+	if err != nil {
+		return fmt.Errorf("invalid input path pattern: %s", err)
+	} else if len(matches) == 0 {
+		return fmt.Errorf("input file not found")
+	} else if len(matches) > 1 {
+		slog.Warn("Input '%s' matches %d files, using '%s'", fs.pattern, len(matches), matches[0])
+	}
+
+	// symlinks may lead anywhere, check the real path
+	path, err := filepath.EvalSymlinks(matches[0])
+	if err != nil {
+		return fmt.Errorf("input file not found")
+	}
+
+	allowed := false
+	for _, dir := range fileSourceDirs {
+		if strings.HasPrefix(path, dir) {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed {
+		return fmt.Errorf("input file is not in %s", strings.Join(fileSourceDirs, " or "))
+	}
+
+	fs.path = path
+	sens.Runtime.Dir = filepath.Dir(fs.path) + "/"
+
+	return nil
+}
+
+func (fs *fileSource) Read() (float64, error) {
+	return readFloat(fs.path)
+}
+
+func (fs *fileSource) ReadText() (string, error) {
+	return readString(fs.path)
+}
+
+func (fs *fileSource) Describe() string {
+	return fs.pattern
+}
+
+func (fs *fileSource) Close() {
+}
+
+func (fs *fileSource) Wrap() float64 {
+	return counterWrap(fs.path)
+}
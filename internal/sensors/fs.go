@@ -0,0 +1,257 @@
+package sensors
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/maxb-odessa/nonsens/internal/config"
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+)
+
+const (
+	MOUNTS_PATH      = "/proc/self/mounts"
+	FILESYSTEMS_PATH = "/proc/filesystems"
+)
+
+// filesystems which are not backed by a block device but still worth watching,
+// network ones are not scanned: statfs() on them may hang, add them manually if needed
+var nodevAllowed = map[string]bool{
+	"zfs": true,
+}
+
+// filesystem usage sensor source: statfs() on mount point
+type fsSource struct {
+	mount   string        // mount point, i.e. /home
+	input   string        // used_percent, used, free or inodes_percent
+	timeout time.Duration // max statfs() wait time
+	pending chan statfs   // result of statfs() being waited for
+}
+
+type statfs struct {
+	st  syscall.Statfs_t
+	err error
+}
+
+func (fs *fsSource) Setup(sens *sensor.Sensor) error {
+
+	if sens.Options.Device == "" {
+		return fmt.Errorf("device is not defined")
+	}
+
+	if sens.Options.Input == "" {
+		sens.Options.Input = "used_percent"
+	}
+
+	fs.mount = sens.Options.Device
+	fs.input = sens.Options.Input
+
+	switch fs.input {
+	case "used_percent", "used", "free", "inodes_percent":
+	default:
+		return fmt.Errorf("unknown filesystem input '%s'", fs.input)
+	}
+
+	sens.Runtime.Dir = fs.mount
+
+	// network filesystem may hang, don't wait longer than poll interval
+	fs.timeout = time.Duration(sens.Options.Poll) * time.Millisecond
+	if fs.timeout <= 0 {
+		fs.timeout = time.Second
+	}
+
+	return nil
+}
+
+func (fs *fsSource) Read() (float64, error) {
+
+	// hung statfs() is waited for again instead of starting another one
+	if fs.pending == nil {
+		fs.pending = make(chan statfs, 1)
+		go func(mount string, ch chan statfs) {
+			var res statfs
+			res.err = syscall.Statfs(mount, &res.st)
+			ch <- res
+		}(fs.mount, fs.pending)
+	}
+
+	var st syscall.Statfs_t
+
+	select {
+	case res := <-fs.pending:
+		fs.pending = nil
+		if res.err != nil {
+			return 0.0, res.err
+		}
+		st = res.st
+	case <-time.After(fs.timeout):
+		return 0.0, fmt.Errorf("statfs timed out after %s", fs.timeout)
+	}
+
+	bsize := float64(st.Bsize)
+	used := float64(st.Blocks-st.Bfree) * bsize
+	avail := float64(st.Bavail) * bsize
+
+	switch fs.input {
+	case "used":
+		return used, nil
+	case "free":
+		return avail, nil
+	case "inodes_percent":
+		// some filesystems (btrfs) have no inodes limit
+		if st.Files == 0 {
+			return 0.0, nil
+		}
+		return float64(st.Files-st.Ffree) * 100.0 / float64(st.Files), nil
+	default:
+		// same as df does: reserved blocks are not counted
+		if used+avail == 0 {
+			return 0.0, nil
+		}
+		return used * 100.0 / (used + avail), nil
+	}
+}
+
+func (fs *fsSource) Describe() string {
+	return fs.mount + "/" + fs.input
+}
+
+func (fs *fsSource) Close() {
+}
+
+// list mount points of real filesystems, skipping pseudo ones and repeated mounts of the same device
+func listMounts() ([]string, error) {
+
+	nodev := make(map[string]bool)
+
+	fp, err := os.Open(FILESYSTEMS_PATH)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == "nodev" {
+			nodev[fields[1]] = !nodevAllowed[fields[1]]
+		}
+	}
+	fp.Close()
+
+	fp, err = os.Open(MOUNTS_PATH)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	mounts := make([]string, 0)
+	seen := make(map[string]bool)
+
+	scanner = bufio.NewScanner(fp)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || nodev[fields[2]] || seen[fields[0]] {
+			continue
+		}
+		seen[fields[0]] = true
+		mounts = append(mounts, unescapeMount(fields[1]))
+	}
+
+	return mounts, scanner.Err()
+}
+
+// mount points have spaces and tabs escaped as octal \040 and \011
+func unescapeMount(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// make "Filesystems" group with usage sensors of all mounted real filesystems
+func scanFilesystems(conf *config.Config) error {
+
+	mounts, err := listMounts()
+	if err != nil {
+		return err
+	}
+
+	group := new(config.Group)
+	group.SetName("Filesystems")
+
+	for _, mount := range mounts {
+
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(mount, &st); err != nil || st.Blocks == 0 {
+			continue
+		}
+
+		se := new(sensor.Sensor)
+		se.Prepare()
+		se.SetDefaults()
+
+		se.Options.Type = "fs"
+		se.Options.Device = mount
+		se.Options.Input = "used_percent"
+		se.Options.Max = 100.0
+		se.Widget.Name = mount + " used"
+		se.Widget.Units = "%"
+
+		SetupSensor(se)
+		conf.AddSensor(se, group)
+
+		se = new(sensor.Sensor)
+		se.Prepare()
+		se.SetDefaults()
+
+		se.Options.Type = "fs"
+		se.Options.Device = mount
+		se.Options.Input = "free"
+		se.Options.Divider = 1024.0 * 1024.0 * 1024.0
+		se.Options.Max = float64(st.Blocks) * float64(st.Bsize) / se.Options.Divider
+		se.Widget.Name = mount + " free"
+		se.Widget.Fractions = 2
+		se.Widget.Units = "GiB"
+		// running out of space is bad, so reverse the colors
+		se.Widget.Color0, se.Widget.Color100 = se.Widget.Color100, se.Widget.Color0
+
+		SetupSensor(se)
+		conf.AddSensor(se, group)
+
+		// some filesystems (btrfs) have no inodes limit
+		if st.Files == 0 {
+			continue
+		}
+
+		se = new(sensor.Sensor)
+		se.Prepare()
+		se.SetDefaults()
+
+		se.Options.Type = "fs"
+		se.Options.Device = mount
+		se.Options.Input = "inodes_percent"
+		se.Options.Max = 100.0
+		se.Widget.Name = mount + " inodes used"
+		se.Widget.Units = "%"
+
+		SetupSensor(se)
+		conf.AddSensor(se, group)
+	}
+
+	addScannedGroup(conf, group)
+
+	return nil
+}
@@ -1,8 +1,10 @@
 package sensors
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -18,6 +20,20 @@ const (
 	HWMON_PATH = "/sys/class/hwmon"
 )
 
+// hwmon limit files suffixes, i.e. temp1_crit
+var hwmonThresholds = []string{
+	"lcrit",
+	"lcrit_hyst",
+	"min",
+	"min_hyst",
+	"max",
+	"max_hyst",
+	"crit",
+	"crit_hyst",
+	"emergency",
+	"emergency_hyst",
+}
+
 // setup all configured sensors
 func setupAllSensors(conf *config.Config) error {
 
@@ -36,40 +52,85 @@ func setupAllSensors(conf *config.Config) error {
 	return nil
 }
 
-// setup single sensor
-func SetupSensor(sens *sensor.Sensor) bool {
+// hwmon sensor source: reads input file from /sys/class/hwmon/hwmonX/ dir
+type hwmonSource struct {
+	device string   // device id, i.e. 0000:09:00.0
+	input  string   // input file name relative to hwmon dir
+	path   string   // full path to input file, may vary across reboots
+	alarms []string // full paths to alarm and fault flag files
+}
+
+func (hs *hwmonSource) Setup(sens *sensor.Sensor) error {
 
 	if sens.Options.Device == "" || sens.Options.Input == "" {
-		slog.Warn("Ignoring invalid sensor '%s/%s'", sens.Options.Device, sens.Options.Input)
-		return false
+		return fmt.Errorf("device or input is not defined")
 	}
 
+	hs.device = sens.Options.Device
+	hs.input = sens.Options.Input
+
 	// update sensor dir
-	if dir := findSensorDir(sens.Options.Device); dir == "" {
-		slog.Warn("Failed to find sensor '%s/%s' dir", sens.Options.Device, sens.Options.Input)
-		return false
+	if dir := findSensorDir(hs.device); dir == "" {
+		return fmt.Errorf("device dir not found")
 	} else {
 		sens.Runtime.Dir = dir
 	}
 
 	// set sensor input file (full path, this is the runtime value)
-	sens.SetInput(sens.Runtime.Dir + sens.Options.Input)
+	hs.path = sens.Runtime.Dir + hs.input
 
-	return true
+	hs.alarms = nil
+	for _, alarm := range sens.Options.Alarms {
+		hs.alarms = append(hs.alarms, sens.Runtime.Dir+alarm)
+	}
+
+	return nil
 }
 
-// scan /sys/class/hwmon/hwmonX dirs and extract all the sensors found
-func ScanAllSensors() *config.Config {
+func (hs *hwmonSource) Read() (float64, error) {
+	return readFloat(hs.path)
+}
 
-	conf := new(config.Config)
+func (hs *hwmonSource) ReadText() (string, error) {
+	return readString(hs.path)
+}
+
+// return names of alarm files with non-zero value
+func (hs *hwmonSource) Alarms() ([]string, error) {
+
+	raised := make([]string, 0)
+
+	for _, path := range hs.alarms {
+		if value, err := readFloat(path); err != nil {
+			return nil, err
+		} else if value != 0 {
+			raised = append(raised, filepath.Base(path))
+		}
+	}
+
+	return raised, nil
+}
+
+func (hs *hwmonSource) Describe() string {
+	return hs.device + "/" + hs.input
+}
+
+func (hs *hwmonSource) Close() {
+}
+
+func (hs *hwmonSource) Wrap() float64 {
+	return counterWrap(hs.path)
+}
+
+// scan /sys/class/hwmon/hwmonX dirs and extract all the sensors found
+func scanHwmon(conf *config.Config) error {
 
 	// make a list of all hwmon devices
 	devices := make(map[string][]string, 0)
 
 	dirs, err := os.ReadDir(HWMON_PATH)
 	if err != nil {
-		slog.Err("Scan of '%s' failed: %s", err)
-		return nil
+		return err
 	}
 
 	for _, dir := range dirs {
@@ -84,47 +145,61 @@ func ScanAllSensors() *config.Config {
 		}
 	}
 
+	// lm-sensors config tweaks, if any
+	lm := loadLmSensors()
+
 	// collect all found sensors
-	columnIdx := 0
 	for device, inputs := range devices {
 
 		dir := findSensorDir(device)
 
+		// known chip?
+		chipName, _ := readString(dir + "name")
+		chip := findChip(chipName)
+		lmChip := lmChipName(dir)
+
 		// make a group
 		group := new(config.Group)
 
 		for _, input := range inputs {
 
+			if chip != nil && chip.ignored(input) {
+				slog.Info("Ignoring sensor '%s/%s' of chip '%s'", device, input, chipName)
+				continue
+			}
+
 			se := new(sensor.Sensor)
 			se.Prepare()
 			se.SetDefaults()
 
 			se.Runtime.Dir = dir
+			se.Options.Type = "hwmon"
 			se.Options.Device = device
 			se.Options.Input = input
 
 			guessSensorOptions(se)
 
+			if chip != nil {
+				chip.apply(se)
+			}
+
+			if lm != nil && !applyLmSensors(lm, lmChip, se) {
+				slog.Info("Ignoring sensor '%s/%s' of chip '%s' by lm-sensors config", device, input, lmChip)
+				continue
+			}
+
 			SetupSensor(se)
 
 			conf.AddSensor(se, group)
 
 		}
 
-		// add non-empty group to 1-st column
-		if len(group.Sensors) > 0 {
-			group.SetId(utils.MakeUID())
-			guessGroupOptions(dir, group)
-			conf.AddGroup(columnIdx, group)
-			// make new column if current is too long (just for beauty)
-			if len(conf.Columns[columnIdx].Groups) >= 4 {
-				columnIdx++
-			}
-		}
+		guessGroupOptions(dir, group)
+		addScannedGroup(conf, group)
 
 	}
 
-	return conf
+	return nil
 }
 
 func guessGroupOptions(dir string, gr *config.Group) {
@@ -198,6 +273,12 @@ func guessSensorOptions(sens *sensor.Sensor) {
 	} else if strings.HasPrefix(inName, "capacity") {
 		sens.Widget.Fractions = 1
 		sens.Widget.Units = "%"
+	} else if strings.HasPrefix(inName, "energy") && strings.HasSuffix(inName, "_input") {
+		// energy counter in uJ, show it as power
+		sens.Options.Rate = true
+		sens.Options.Divider = 1_000_000.0
+		sens.Widget.Fractions = 1
+		sens.Widget.Units = "Watts"
 	} else if strings.HasPrefix(inName, "energy") {
 		sens.Options.Divider = 1_000_000.0
 		sens.Widget.Fractions = 1
@@ -206,6 +287,24 @@ func guessSensorOptions(sens *sensor.Sensor) {
 		sens.Widget.Units = "units"
 	}
 
+	// find alarm and fault flags of the input, i.e. temp1_alarm, temp1_crit_alarm, fan1_fault
+	sens.Options.Alarms = nil
+	for _, pattern := range []string{"_alarm", "_*_alarm", "_fault"} {
+		files, _ := filepath.Glob(sens.Runtime.Dir + inPrefix + pattern)
+		for _, file := range files {
+			sens.Options.Alarms = append(sens.Options.Alarms, strings.TrimPrefix(file, sens.Runtime.Dir))
+		}
+	}
+
+	guessLimitOptions(sens)
+
+}
+
+// read raw hardware min/max and limits of the input, scaled by divider only
+func guessLimitOptions(sens *sensor.Sensor) {
+
+	inPrefix := strings.Split(sens.Options.Input, "_")[0]
+
 	// guess sensor min/max value
 	if sens.Options.Min == 0.0 && sens.Options.Max == 0.0 {
 
@@ -224,8 +323,84 @@ func guessSensorOptions(sens *sensor.Sensor) {
 		sens.Options.Min /= sens.Options.Divider
 		sens.Options.Max /= sens.Options.Divider
 
+		// unset limits
+		if sens.Options.Max <= sens.Options.Min {
+			sens.Options.Min, sens.Options.Max = 0.0, 0.0
+		}
+
+	}
+
+	// read hardware limits and use them for gauge max and colors
+	sens.Options.Thresholds = nil
+	for _, name := range hwmonThresholds {
+		if value, err := readFloat(sens.Runtime.Dir + inPrefix + "_" + name); err == nil {
+			if sens.Options.Thresholds == nil {
+				sens.Options.Thresholds = make(map[string]float64)
+			}
+			sens.Options.Thresholds[name] = value / sens.Options.Divider
+		}
+	}
+
+	// unset limits usually read as zeroes, i.e. inN_min = inN_max = 0 on many boards
+	if min, ok := sens.Options.Thresholds["min"]; ok {
+		if max, ok := sens.Options.Thresholds["max"]; ok && max <= min {
+			delete(sens.Options.Thresholds, "min")
+			delete(sens.Options.Thresholds, "max")
+		}
+	}
+	for name, value := range sens.Options.Thresholds {
+		if !sensor.IsLowThreshold(name) && value <= 0.0 {
+			delete(sens.Options.Thresholds, name)
+		}
+	}
+
+	if len(sens.Options.Thresholds) > 0 {
+		guessThresholdOptions(sens)
+	}
+
+}
+
+// is it a known hwmon limit name?
+func isHwmonThreshold(name string) bool {
+	for _, th := range hwmonThresholds {
+		if th == name {
+			return true
+		}
+	}
+	return false
+}
+
+// make gauge max the highest hardware limit and break colors at the next highest one
+func guessThresholdOptions(sens *sensor.Sensor) {
+
+	limits := make([]float64, 0)
+	for name, value := range sens.Options.Thresholds {
+		if !sensor.IsLowThreshold(name) && !strings.HasSuffix(name, "_hyst") {
+			limits = append(limits, value)
+		}
+	}
+
+	if sens.Options.Max > sens.Options.Min {
+		limits = append(limits, sens.Options.Max)
+	}
+
+	if len(limits) == 0 {
+		return
 	}
 
+	sort.Float64s(limits)
+
+	if top := limits[len(limits)-1]; top > sens.Options.Min {
+		sens.Options.Max = top
+		slog.Info("Using Max value '%f' for sensor '%s/%s'", sens.Options.Max, sens.Options.Device, sens.Options.Input)
+	}
+
+	for i := len(limits) - 2; i >= 0; i-- {
+		if limits[i] > sens.Options.Min && limits[i] < sens.Options.Max {
+			sens.Widget.ColorNP = int((limits[i] - sens.Options.Min) * 100.0 / (sens.Options.Max - sens.Options.Min))
+			break
+		}
+	}
 }
 
 func getDeviceInputs(dir string) []string {
@@ -284,7 +459,7 @@ func findSensorDir(device string) string {
 
 	dirs, err := os.ReadDir(HWMON_PATH)
 	if err != nil {
-		slog.Err("Scan of '%s' failed: %s", err)
+		slog.Err("Scan of '%s' failed: %s", HWMON_PATH, err)
 		return ""
 	}
 
@@ -0,0 +1,266 @@
+package sensors
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/maxb-odessa/nonsens/internal/config"
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+
+	"github.com/maxb-odessa/slog"
+)
+
+const (
+	IIO_PATH = "/sys/bus/iio/devices"
+)
+
+// iio sensor source: reads iio channel and applies (raw + offset) * scale to it
+type iioSource struct {
+	device string // device id, i.e. HID-SENSOR-200041.1.auto
+	input  string // channel input file name, i.e. in_illuminance_raw
+	path   string // full path to input file
+	scale  string // full path to channel scale file, if any
+	offset string // full path to channel offset file, if any
+}
+
+func (is *iioSource) Setup(sens *sensor.Sensor) error {
+
+	if sens.Options.Device == "" || sens.Options.Input == "" {
+		return fmt.Errorf("device or input is not defined")
+	}
+
+	is.device = sens.Options.Device
+	is.input = sens.Options.Input
+
+	if dir := findIioDir(is.device); dir == "" {
+		return fmt.Errorf("device dir not found")
+	} else {
+		sens.Runtime.Dir = dir
+	}
+
+	is.path = sens.Runtime.Dir + is.input
+
+	// processed (*_input) values are already scaled
+	if strings.HasSuffix(is.input, "_raw") {
+		is.scale = iioChannelAttr(sens.Runtime.Dir, is.input, "scale")
+		is.offset = iioChannelAttr(sens.Runtime.Dir, is.input, "offset")
+	}
+
+	return nil
+}
+
+func (is *iioSource) Read() (float64, error) {
+	var offset, scale float64 = 0.0, 1.0
+
+	value, err := readFloat(is.path)
+	if err != nil {
+		return 0.0, err
+	}
+
+	// scale and offset may be changed by the driver at any time, so re-read them
+	if is.offset != "" {
+		if offset, err = readFloat(is.offset); err != nil {
+			return 0.0, err
+		}
+	}
+
+	if is.scale != "" {
+		if scale, err = readFloat(is.scale); err != nil {
+			return 0.0, err
+		}
+	}
+
+	return (value + offset) * scale, nil
+}
+
+func (is *iioSource) Describe() string {
+	return is.device + "/" + is.input
+}
+
+func (is *iioSource) Close() {
+}
+
+// find channel attribute file: per channel one (in_accel_x_scale) or shared by channel type (in_accel_scale)
+func iioChannelAttr(dir, input, attr string) string {
+
+	channel := strings.TrimSuffix(strings.TrimSuffix(input, "_raw"), "_input")
+
+	for _, name := range []string{channel + "_" + attr, "in_" + iioChannelType(input) + "_" + attr} {
+		if _, err := os.Stat(dir + name); err == nil {
+			return dir + name
+		}
+	}
+
+	return ""
+}
+
+// extract channel type from input name, i.e. "accel" from "in_accel_x_raw" or "voltage" from "in_voltage0_raw"
+func iioChannelType(input string) string {
+	typ := strings.TrimPrefix(input, "in_")
+	if i := strings.IndexAny(typ, "0123456789_"); i > 0 {
+		typ = typ[:i]
+	}
+	return typ
+}
+
+// scan /sys/bus/iio/devices/iio:deviceX dirs and extract all the channels found
+func scanIio(conf *config.Config) error {
+
+	dirs, err := os.ReadDir(IIO_PATH)
+	if os.IsNotExist(err) {
+		return nil // no iio devices at all, that's ok
+	} else if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+
+		if !strings.HasPrefix(dir.Name(), "iio:device") {
+			continue
+		}
+
+		dirName := IIO_PATH + "/" + dir.Name() + "/"
+		device := iioDeviceId(dirName)
+		if device == "" {
+			continue
+		}
+
+		group := new(config.Group)
+
+		for _, input := range getIioInputs(dirName) {
+
+			se := new(sensor.Sensor)
+			se.Prepare()
+			se.SetDefaults()
+
+			se.Runtime.Dir = dirName
+			se.Options.Type = "iio"
+			se.Options.Device = device
+			se.Options.Input = input
+
+			guessIioOptions(se)
+
+			SetupSensor(se)
+
+			conf.AddSensor(se, group)
+		}
+
+		guessGroupOptions(dirName, group)
+		addScannedGroup(conf, group)
+	}
+
+	return nil
+}
+
+// iio device id is its parent device name, iio:deviceX numbers may vary across reboots
+func iioDeviceId(dir string) string {
+	if dev, err := filepath.EvalSymlinks(dir); err != nil {
+		slog.Warn("Could not resolve path '%s': %s", dir, err)
+		return ""
+	} else {
+		return filepath.Base(filepath.Dir(dev))
+	}
+}
+
+// find iio device real dir under /sys
+func findIioDir(device string) string {
+
+	dirs, err := os.ReadDir(IIO_PATH)
+	if err != nil {
+		slog.Err("Scan of '%s' failed: %s", IIO_PATH, err)
+		return ""
+	}
+
+	for _, dir := range dirs {
+		dirName := IIO_PATH + "/" + dir.Name() + "/"
+		if strings.HasPrefix(dir.Name(), "iio:device") && iioDeviceId(dirName) == device {
+			return dirName
+		}
+	}
+
+	return ""
+}
+
+// list channel input files, processed value is preferred over raw one
+func getIioInputs(dir string) []string {
+
+	inputs := make([]string, 0)
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return inputs
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasPrefix(name, "in_") {
+			continue
+		}
+		if strings.HasSuffix(name, "_input") {
+			inputs = append(inputs, name)
+		} else if strings.HasSuffix(name, "_raw") {
+			if _, err := os.Stat(dir + strings.TrimSuffix(name, "_raw") + "_input"); err != nil {
+				inputs = append(inputs, name)
+			}
+		}
+	}
+
+	return inputs
+}
+
+func guessIioOptions(sens *sensor.Sensor) {
+
+	channel := strings.TrimSuffix(strings.TrimSuffix(sens.Options.Input, "_raw"), "_input")
+
+	// guess sensor name
+	if label, err := readString(sens.Runtime.Dir + channel + "_label"); err == nil && label != "" {
+		sens.Widget.Name = label
+	} else {
+		sens.Widget.Name = strings.ReplaceAll(strings.TrimPrefix(channel, "in_"), "_", " ")
+	}
+
+	// guess units, scaled values units are defined by iio ABI
+	switch iioChannelType(sens.Options.Input) {
+	case "illuminance":
+		sens.Widget.Units = "lux"
+	case "temp":
+		sens.Options.Divider = 1_000.0
+		sens.Widget.Units = `&deg;C`
+	case "humidityrelative":
+		sens.Options.Divider = 1_000.0
+		sens.Options.Max = 100.0
+		sens.Widget.Units = "%"
+	case "pressure":
+		sens.Widget.Fractions = 2
+		sens.Widget.Units = "kPa"
+	case "accel":
+		sens.Widget.Fractions = 2
+		sens.Widget.Units = "m/s&sup2;"
+	case "anglvel":
+		sens.Widget.Fractions = 2
+		sens.Widget.Units = "rad/s"
+	case "magn":
+		sens.Widget.Fractions = 3
+		sens.Widget.Units = "Gauss"
+	case "voltage":
+		sens.Options.Divider = 1_000.0
+		sens.Widget.Fractions = 3
+		sens.Widget.Units = "Volts"
+	case "current":
+		sens.Options.Divider = 1_000.0
+		sens.Widget.Fractions = 3
+		sens.Widget.Units = "Amps"
+	case "power":
+		sens.Options.Divider = 1_000.0
+		sens.Widget.Units = "Watts"
+	case "concentration":
+		sens.Widget.Units = "ppm"
+	case "distance":
+		sens.Widget.Fractions = 2
+		sens.Widget.Units = "m"
+	default:
+		sens.Widget.Units = "units"
+	}
+}
@@ -0,0 +1,233 @@
+package sensors
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/maxb-odessa/nonsens/internal/config"
+	"github.com/maxb-odessa/nonsens/internal/expr"
+	"github.com/maxb-odessa/nonsens/internal/lmsensors"
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+	"github.com/maxb-odessa/nonsens/internal/utils"
+
+	"github.com/maxb-odessa/slog"
+)
+
+// lm-sensors config file or dir, default system files are used if empty
+var lmSensorsPath string
+
+// load lm-sensors config, nil if there is none
+func loadLmSensors() *lmsensors.Config {
+
+	var lm *lmsensors.Config
+	var err error
+
+	if lmSensorsPath == "" {
+		lm, err = lmsensors.LoadDefault()
+	} else {
+		lm = new(lmsensors.Config)
+		err = lm.Load(lmSensorsPath)
+	}
+
+	if err != nil {
+		slog.Warn("Failed to load lm-sensors config: %s", err)
+		return nil
+	}
+
+	if len(lm.Chips) == 0 {
+		return nil
+	}
+
+	return lm
+}
+
+// make lm-sensors chip name of hwmon dir, i.e. "it8718-isa-0290" or "amdgpu-pci-0900"
+func lmChipName(dir string) string {
+
+	name, err := readString(dir + "name")
+	if err != nil {
+		return ""
+	}
+
+	dev, err := filepath.EvalSymlinks(dir + "device")
+	if err != nil {
+		return name + "-virtual-0"
+	}
+
+	devName := filepath.Base(dev)
+
+	subsys, err := filepath.EvalSymlinks(dev + "/subsystem")
+	if err != nil {
+		return name + "-virtual-0"
+	}
+
+	switch filepath.Base(subsys) {
+
+	case "i2c":
+		// 0-002d: bus, address
+		var bus, addr int
+		if n, _ := fmt.Sscanf(devName, "%d-%x", &bus, &addr); n == 2 {
+			return fmt.Sprintf("%s-i2c-%d-%02x", name, bus, addr)
+		}
+
+	case "pci":
+		// 0000:09:00.0: domain, bus, slot, function
+		var domain, bus, slot, fn int
+		if n, _ := fmt.Sscanf(devName, "%x:%x:%x.%x", &domain, &bus, &slot, &fn); n == 4 {
+			return fmt.Sprintf("%s-pci-%04x", name, (domain<<16)+(bus<<8)+(slot<<3)+fn)
+		}
+
+	case "platform", "of_platform":
+		// it87.656: name, decimal address
+		addr := 0
+		if i := strings.LastIndex(devName, "."); i >= 0 {
+			addr, _ = strconv.Atoi(devName[i+1:])
+		}
+		return fmt.Sprintf("%s-isa-%04x", name, addr)
+
+	case "spi":
+		// spi0.1: bus, chip select
+		var bus, addr int
+		if n, _ := fmt.Sscanf(devName, "spi%d.%d", &bus, &addr); n == 2 {
+			return fmt.Sprintf("%s-spi-%d-%x", name, bus, addr)
+		}
+
+	case "acpi":
+		return name + "-acpi-0"
+
+	}
+
+	return name + "-virtual-0"
+}
+
+// apply lm-sensors chip feature statements to hwmon sensor, return false if it is ignored
+func applyLmSensors(lm *lmsensors.Config, chipName string, sens *sensor.Sensor) bool {
+
+	// lm-sensors feature is hwmon input prefix, i.e. "in3" for "in3_input"
+	if strings.Contains(sens.Options.Input, "/") {
+		return true
+	}
+
+	feature := lm.Feature(chipName, strings.Split(sens.Options.Input, "_")[0])
+
+	if feature.Ignored {
+		return false
+	}
+
+	if feature.Label != "" {
+		sens.Widget.Name = utils.SafeHTML(feature.Label)
+	}
+
+	if feature.Compute != "" && feature.Compute != sens.Options.Compute {
+		if _, err := parseCompute(feature.Compute); err != nil {
+			slog.Warn("Invalid compute expression '%s' for sensor '%s/%s': %s", feature.Compute, sens.Options.Device, sens.Options.Input, err)
+		} else {
+			if sens.Options.Compute != "" {
+				// limits are computed by another expression, start over from the raw ones
+				sens.Options.Compute = ""
+				sens.Options.Min, sens.Options.Max = 0.0, 0.0
+				guessLimitOptions(sens)
+			}
+			setCompute(sens, feature.Compute)
+		}
+	}
+
+	for attr, value := range feature.Sets {
+
+		if !isHwmonThreshold(attr) {
+			slog.Debug(1, "Ignoring lm-sensors 'set %s' for sensor '%s/%s'", attr, sens.Options.Device, sens.Options.Input)
+			continue
+		}
+
+		e, err := expr.Parse(value)
+		if err == nil && len(e.Vars()) > 0 {
+			err = fmt.Errorf("other features are not supported")
+		}
+
+		var limit float64
+		if err == nil {
+			limit, err = e.Eval(0.0, nil)
+		}
+
+		if err != nil {
+			slog.Warn("Invalid 'set %s' expression '%s' for sensor '%s/%s': %s", attr, value, sens.Options.Device, sens.Options.Input, err)
+			continue
+		}
+
+		switch attr {
+		case "min":
+			sens.Options.Min = limit
+		case "max":
+			sens.Options.Max = limit
+		}
+
+		if sens.Options.Thresholds == nil {
+			sens.Options.Thresholds = make(map[string]float64)
+		}
+		sens.Options.Thresholds[attr] = limit
+	}
+
+	if len(feature.Sets) > 0 && len(sens.Options.Thresholds) > 0 {
+		guessThresholdOptions(sens)
+	}
+
+	return true
+}
+
+// apply lm-sensors config to configured hwmon sensors, ignored sensors are removed,
+// return number of changed sensors
+func ApplyLmSensors(conf *config.Config) (int, error) {
+
+	lm := loadLmSensors()
+	if lm == nil {
+		return 0, fmt.Errorf("no lm-sensors config found")
+	}
+
+	changed := 0
+	chipNames := make(map[string]string) // device -> chip name
+
+	for _, sens := range conf.AllSensors() {
+
+		if sens.Options.Type != "hwmon" {
+			continue
+		}
+
+		chipName, ok := chipNames[sens.Options.Device]
+		if !ok {
+			if dir := findSensorDir(sens.Options.Device); dir != "" {
+				chipName = lmChipName(dir)
+			}
+			chipNames[sens.Options.Device] = chipName
+		}
+
+		if chipName == "" {
+			continue
+		}
+
+		sens.Stop()
+
+		sens.Lock()
+		before := sens.Json()
+		keep := applyLmSensors(lm, chipName, sens)
+		sens.Unlock()
+
+		if !keep {
+			slog.Info("Removing sensor '%s' ignored by lm-sensors config", sens.Name)
+			CloseSensor(sens)
+			conf.RemoveSensor(sens)
+			changed++
+			continue
+		}
+
+		if sens.Json() != before {
+			SetupSensor(sens)
+			changed++
+		}
+
+		sens.Start(sensChan)
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,180 @@
+package sensors
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/maxb-odessa/nonsens/internal/config"
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+)
+
+const (
+	MEMINFO_PATH = "/proc/meminfo"
+)
+
+// values calculated of /proc/meminfo fields: name -> total, free
+var meminfoUsed = map[string][2]string{
+	"MemUsed":        {"MemTotal", "MemAvailable"},
+	"SwapUsed":       {"SwapTotal", "SwapFree"},
+	"HugePages_Used": {"HugePages_Total", "HugePages_Free"},
+}
+
+// memory sensor source: reads /proc/meminfo field (kB or pages)
+// or calculates used amount: MemUsed, SwapUsed or HugePages_Used
+type meminfoSource struct {
+	input string // meminfo field name, i.e. MemAvailable
+}
+
+func (ms *meminfoSource) Setup(sens *sensor.Sensor) error {
+
+	if sens.Options.Input == "" {
+		return fmt.Errorf("input is not defined")
+	}
+
+	ms.input = sens.Options.Input
+
+	info, err := ReadMeminfo()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := info[ms.input]; !ok {
+		return fmt.Errorf("unknown meminfo field '%s'", ms.input)
+	}
+
+	return nil
+}
+
+func (ms *meminfoSource) Read() (float64, error) {
+
+	info, err := ReadMeminfo()
+	if err != nil {
+		return 0.0, err
+	}
+
+	if value, ok := info[ms.input]; !ok {
+		return 0.0, fmt.Errorf("meminfo field '%s' not found", ms.input)
+	} else {
+		return value, nil
+	}
+}
+
+func (ms *meminfoSource) Describe() string {
+	return "meminfo/" + ms.input
+}
+
+func (ms *meminfoSource) Close() {
+}
+
+// read /proc/meminfo fields and add calculated ones
+func ReadMeminfo() (map[string]float64, error) {
+
+	fp, err := os.Open(MEMINFO_PATH)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	info := make(map[string]float64)
+
+	// lines look like "MemTotal:       32762364 kB"
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if value, err := strconv.ParseFloat(fields[1], 64); err == nil {
+			info[strings.TrimSuffix(fields[0], ":")] = value
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for name, fields := range meminfoUsed {
+		total, ok1 := info[fields[0]]
+		free, ok2 := info[fields[1]]
+		if ok1 && ok2 {
+			info[name] = total - free
+		}
+	}
+
+	return info, nil
+}
+
+// make "Memory" group of memory, swap and huge pages usage sensors
+func scanMeminfo(conf *config.Config) error {
+
+	info, err := ReadMeminfo()
+	if err != nil {
+		return err
+	}
+
+	group := new(config.Group)
+	group.SetName("Memory")
+
+	inputs := []struct {
+		input string // meminfo field
+		name  string // visible name
+		total string // field with max value
+	}{
+		{"MemUsed", "Used", "MemTotal"},
+		{"MemAvailable", "Available", "MemTotal"},
+		{"Cached", "Cached", "MemTotal"},
+		{"Dirty", "Dirty", ""},
+		{"SwapUsed", "Swap used", "SwapTotal"},
+		{"HugePages_Used", "Huge pages used", "HugePages_Total"},
+	}
+
+	for _, in := range inputs {
+
+		if _, ok := info[in.input]; !ok {
+			continue
+		}
+
+		// no swap or huge pages configured
+		if in.total != "" && info[in.total] == 0 {
+			continue
+		}
+
+		se := new(sensor.Sensor)
+		se.Prepare()
+		se.SetDefaults()
+
+		se.Options.Type = "meminfo"
+		se.Options.Input = in.input
+		se.Widget.Name = in.name
+
+		// huge pages are counted in pages, everything else in kB
+		if strings.HasPrefix(in.input, "HugePages") {
+			se.Widget.Fractions = 0
+			se.Widget.Units = "pages"
+		} else {
+			se.Options.Divider = 1024.0
+			se.Widget.Fractions = 0
+			se.Widget.Units = "MiB"
+		}
+
+		if in.total != "" {
+			se.Options.Max = info[in.total] / se.Options.Divider
+		}
+
+		// low available memory is bad, so reverse the colors
+		if in.input == "MemAvailable" {
+			se.Widget.Color0, se.Widget.Color100 = se.Widget.Color100, se.Widget.Color0
+		}
+
+		SetupSensor(se)
+
+		conf.AddSensor(se, group)
+	}
+
+	addScannedGroup(conf, group)
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package sensors
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/maxb-odessa/nonsens/internal/config"
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+)
+
+const (
+	NET_PATH = "/sys/class/net"
+
+	ARPHRD_LOOPBACK = "772" // loopback interface type
+)
+
+// network interface sensor source: converts /sys/class/net/IF/statistics/ counters into per second rates
+type netSource struct {
+	iface string      // interface name, i.e. eth0
+	input string      // statistics file, i.e. rx_bytes
+	path  string      // full path to statistics file
+	rate  sensor.Rate // counter to rate converter
+}
+
+func (ns *netSource) Setup(sens *sensor.Sensor) error {
+
+	if sens.Options.Device == "" || sens.Options.Input == "" {
+		return fmt.Errorf("device or input is not defined")
+	}
+
+	ns.iface = sens.Options.Device
+	ns.input = sens.Options.Input
+
+	sens.Runtime.Dir = NET_PATH + "/" + ns.iface + "/statistics/"
+	ns.path = sens.Runtime.Dir + ns.input
+
+	// rate is counted from the first read, the sensor is started a moment later
+	if _, err := readFloat(ns.path); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ns *netSource) Read() (float64, error) {
+	if value, err := readFloat(ns.path); err != nil {
+		return 0.0, err
+	} else {
+		rate, _ := ns.rate.Update(value, time.Now())
+		// need at least two counter values to calculate the rate
+		if !ns.rate.Ready() {
+			return 0.0, errors.New("collecting data")
+		}
+		return rate, nil
+	}
+}
+
+func (ns *netSource) Describe() string {
+	return ns.iface + "/" + ns.input
+}
+
+func (ns *netSource) Close() {
+}
+
+// make a group for each non-loopback network interface
+func scanNet(conf *config.Config) error {
+
+	dirs, err := os.ReadDir(NET_PATH)
+	if err != nil {
+		return err
+	}
+
+	inputs := []struct {
+		input string
+		name  string
+	}{
+		{"rx_bytes", "RX"},
+		{"tx_bytes", "TX"},
+		{"rx_packets", "RX packets"},
+		{"tx_packets", "TX packets"},
+		{"rx_errors", "RX errors"},
+		{"tx_errors", "TX errors"},
+		{"rx_dropped", "RX dropped"},
+		{"tx_dropped", "TX dropped"},
+	}
+
+	for _, dir := range dirs {
+
+		dirName := NET_PATH + "/" + dir.Name() + "/"
+
+		if typ, err := readString(dirName + "type"); err != nil || typ == ARPHRD_LOOPBACK {
+			continue
+		}
+
+		// link speed in Mbit/s, unknown for wireless and virtual interfaces
+		speed, _ := readFloat(dirName + "speed")
+
+		group := new(config.Group)
+		group.SetName(dir.Name())
+
+		for _, in := range inputs {
+
+			se := new(sensor.Sensor)
+			se.Prepare()
+			se.SetDefaults()
+
+			se.Options.Type = "net"
+			se.Options.Device = dir.Name()
+			se.Options.Input = in.input
+			se.Widget.Name = in.name
+
+			if in.input == "rx_bytes" || in.input == "tx_bytes" {
+				se.Options.Divider = 1024.0
+				se.Widget.Units = "KiB/s"
+				if speed > 0 {
+					se.Options.Max = speed * 1_000_000.0 / 8.0 / se.Options.Divider
+				}
+			} else {
+				se.Widget.Fractions = 0
+				se.Widget.Units = "pkt/s"
+			}
+
+			SetupSensor(se)
+
+			conf.AddSensor(se, group)
+		}
+
+		addScannedGroup(conf, group)
+	}
+
+	return nil
+}
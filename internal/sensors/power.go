@@ -0,0 +1,266 @@
+package sensors
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/maxb-odessa/nonsens/internal/config"
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+)
+
+const (
+	POWER_SUPPLY_PATH = "/sys/class/power_supply"
+)
+
+// power supply sensor source: batteries, AC adapters, UPSes
+// inputs are /sys/class/power_supply/X/ files plus some calculated values:
+// time_to_empty (minutes), time_to_full (minutes) and health (percents)
+type powerSource struct {
+	supply string // power supply name, i.e. BAT0
+	input  string // input file name or calculated value name
+	dir    string // power supply dir
+}
+
+func (ps *powerSource) Setup(sens *sensor.Sensor) error {
+
+	if sens.Options.Device == "" || sens.Options.Input == "" {
+		return fmt.Errorf("device or input is not defined")
+	}
+
+	ps.supply = sens.Options.Device
+	ps.input = sens.Options.Input
+	ps.dir = POWER_SUPPLY_PATH + "/" + ps.supply + "/"
+
+	if _, err := os.Stat(ps.dir); err != nil {
+		return fmt.Errorf("power supply not found")
+	}
+
+	sens.Runtime.Dir = ps.dir
+
+	return nil
+}
+
+func (ps *powerSource) Read() (float64, error) {
+	switch ps.input {
+	case "time_to_empty":
+		return ps.timeTo("Discharging", "time_to_empty_now", "", "_now")
+	case "time_to_full":
+		return ps.timeTo("Charging", "time_to_full_now", "_now", "_full")
+	case "health":
+		return ps.health()
+	default:
+		return readFloat(ps.dir + ps.input)
+	}
+}
+
+func (ps *powerSource) ReadText() (string, error) {
+	return readString(ps.dir + ps.input)
+}
+
+func (ps *powerSource) Describe() string {
+	return ps.supply + "/" + ps.input
+}
+
+func (ps *powerSource) Close() {
+}
+
+// calculate time (in minutes) to empty or full battery, use provided value if any,
+// energy (uWh) and power (uW) or charge (uAh) and current (uA) otherwise
+func (ps *powerSource) timeTo(status, provided, fromSuffix, toSuffix string) (float64, error) {
+
+	if st, err := readString(ps.dir + "status"); err != nil {
+		return 0.0, err
+	} else if st != status {
+		return 0.0, nil
+	}
+
+	if secs, err := readFloat(ps.dir + provided); err == nil {
+		return secs / 60.0, nil
+	}
+
+	for _, pair := range [][2]string{{"energy", "power_now"}, {"charge", "current_now"}} {
+
+		rate, err := readFloat(ps.dir + pair[1])
+		if err != nil || rate == 0 {
+			continue
+		}
+
+		// time to empty: what's left now, time to full: what's missing to full
+		to, err := readFloat(ps.dir + pair[0] + toSuffix)
+		if err != nil {
+			continue
+		}
+
+		from := 0.0
+		if fromSuffix != "" {
+			if from, err = readFloat(ps.dir + pair[0] + fromSuffix); err != nil {
+				continue
+			}
+		}
+
+		// some drivers report negative rates while discharging
+		if rate < 0 {
+			rate = -rate
+		}
+
+		return (to - from) / rate * 60.0, nil
+	}
+
+	return 0.0, errors.New("no energy or charge rate data")
+}
+
+// calculate battery health: full capacity vs design capacity, in percents
+func (ps *powerSource) health() (float64, error) {
+
+	for _, prefix := range []string{"energy", "charge"} {
+		full, err := readFloat(ps.dir + prefix + "_full")
+		if err != nil {
+			continue
+		}
+		design, err := readFloat(ps.dir + prefix + "_full_design")
+		if err != nil || design == 0 {
+			continue
+		}
+		return full * 100.0 / design, nil
+	}
+
+	return 0.0, errors.New("no full and design capacity data")
+}
+
+// make a group for each power supply
+func scanPowerSupplies(conf *config.Config) error {
+
+	dirs, err := os.ReadDir(POWER_SUPPLY_PATH)
+	if os.IsNotExist(err) {
+		return nil // no power supplies class at all
+	} else if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+
+		dirName := POWER_SUPPLY_PATH + "/" + dir.Name() + "/"
+
+		group := new(config.Group)
+		group.SetName(dir.Name())
+
+		addSensor := func(input string, name string, setup func(se *sensor.Sensor)) {
+			se := new(sensor.Sensor)
+			se.Prepare()
+			se.SetDefaults()
+
+			se.Options.Type = "power_supply"
+			se.Options.Device = dir.Name()
+			se.Options.Input = input
+			se.Widget.Name = name
+
+			setup(se)
+
+			SetupSensor(se)
+
+			conf.AddSensor(se, group)
+		}
+
+		exists := func(files ...string) bool {
+			for _, file := range files {
+				if _, err := os.Stat(dirName + file); err != nil {
+					return false
+				}
+			}
+			return true
+		}
+
+		if exists("status") {
+			addSensor("status", "Status", func(se *sensor.Sensor) {
+				se.Options.Kind = sensor.KIND_TEXT
+				se.Options.Poll = 5000
+				se.Options.States = []sensor.State{
+					{Value: "Charging", Color: "#00FF00"},
+					{Value: "Discharging", Color: "#FFBF00"},
+					{Value: "Full", Color: "#00FF00"},
+					{Value: "Not charging", Color: "#A0A0A0"},
+				}
+			})
+		}
+
+		if exists("online") {
+			addSensor("online", "Online", func(se *sensor.Sensor) {
+				se.Options.Max = 1.0
+				se.Widget.Fractions = 0
+				se.Widget.Units = ""
+			})
+		}
+
+		if exists("capacity") {
+			addSensor("capacity", "Capacity", func(se *sensor.Sensor) {
+				se.Options.Max = 100.0
+				se.Widget.Fractions = 0
+				se.Widget.Units = "%"
+				// low charge is bad, so reverse the colors
+				se.Widget.Color0, se.Widget.Color100 = se.Widget.Color100, se.Widget.Color0
+			})
+		}
+
+		for _, in := range []struct {
+			input string
+			name  string
+			units string
+		}{
+			{"voltage_now", "Voltage", "Volts"},
+			{"current_now", "Current", "Amps"},
+			{"power_now", "Power", "Watts"},
+			{"energy_now", "Energy", "Wh"},
+			{"charge_now", "Charge", "Ah"},
+		} {
+			if exists(in.input) {
+				units := in.units
+				addSensor(in.input, in.name, func(se *sensor.Sensor) {
+					se.Options.Divider = 1_000_000.0
+					se.Widget.Fractions = 2
+					se.Widget.Units = units
+				})
+			}
+		}
+
+		if exists("temp") {
+			addSensor("temp", "Temperature", func(se *sensor.Sensor) {
+				se.Options.Divider = 10.0
+				se.Widget.Units = `&deg;C`
+			})
+		}
+
+		if exists("status") && (exists("energy_now", "power_now") || exists("charge_now", "current_now") || exists("time_to_empty_now")) {
+			addSensor("time_to_empty", "Time to empty", func(se *sensor.Sensor) {
+				se.Options.Poll = 5000
+				se.Widget.Fractions = 0
+				se.Widget.Units = "min"
+				se.Widget.Color0, se.Widget.Color100 = se.Widget.Color100, se.Widget.Color0
+			})
+		}
+
+		if exists("energy_full", "energy_full_design") || exists("charge_full", "charge_full_design") {
+			addSensor("health", "Health", func(se *sensor.Sensor) {
+				se.Options.Max = 100.0
+				se.Options.Poll = 60000
+				se.Widget.Units = "%"
+				se.Widget.Color0, se.Widget.Color100 = se.Widget.Color100, se.Widget.Color0
+			})
+		}
+
+		// make group name of supply model if known
+		if model, err := readString(dirName + "model_name"); err == nil && model != "" {
+			if manufacturer, err := readString(dirName + "manufacturer"); err == nil && manufacturer != "" {
+				model = manufacturer + " " + model
+			}
+			group.SetName(dir.Name() + "/" + model)
+			if len(group.Name) > 30 {
+				group.Name = group.Name[0:30] // don't make group name too long
+			}
+		}
+
+		addScannedGroup(conf, group)
+	}
+
+	return nil
+}
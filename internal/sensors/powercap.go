@@ -0,0 +1,126 @@
+package sensors
+
+import (
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/maxb-odessa/nonsens/internal/config"
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+
+	"github.com/maxb-odessa/slog"
+)
+
+const (
+	POWERCAP_PATH = "/sys/class/powercap"
+)
+
+// visible names of powercap zones
+var powercapNames = map[string]string{
+	"core":   "core",
+	"uncore": "uncore",
+	"dram":   "dram",
+	"psys":   "platform",
+}
+
+// scan RAPL powercap zones (intel-rapl:X, intel-rapl:X:Y and alike, AMD cpus use them too)
+// and make power sensors of their energy counters
+func scanPowercap(conf *config.Config) error {
+
+	zones, err := filepath.Glob(POWERCAP_PATH + "/*-rapl:*")
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(zones)
+
+	// count packages to tell their subzones apart
+	packages := 0
+	for _, zone := range zones {
+		if strings.Count(filepath.Base(zone), ":") == 1 {
+			packages++
+		}
+	}
+
+	group := new(config.Group)
+	group.SetName("CPU power")
+
+	for _, zone := range zones {
+
+		name, err := readString(zone + "/name")
+		if err != nil {
+			continue
+		}
+
+		input := zone + "/energy_uj"
+
+		// energy counters are readable by root only on most modern kernels
+		if _, err := readFloat(input); err != nil {
+			slog.Warn("Skipping powercap zone '%s': %s", zone, err)
+			continue
+		}
+
+		se := new(sensor.Sensor)
+		se.Prepare()
+		se.SetDefaults()
+
+		se.Options.Type = "file"
+		se.Options.Input = input
+		se.Options.Rate = true
+		se.Options.Divider = 1_000_000.0
+		se.Widget.Units = "Watts"
+
+		zoneId := strings.Split(filepath.Base(zone), ":")
+		if strings.HasPrefix(name, "package-") {
+			se.Widget.Name = "CPU package"
+			if packages > 1 {
+				se.Widget.Name += " " + strings.TrimPrefix(name, "package-")
+			}
+		} else if vname, ok := powercapNames[name]; ok {
+			se.Widget.Name = vname
+			if packages > 1 && len(zoneId) > 2 {
+				se.Widget.Name += " " + zoneId[1]
+			}
+		} else {
+			se.Widget.Name = name
+		}
+
+		guessPowercapOptions(zone, se)
+
+		SetupSensor(se)
+
+		conf.AddSensor(se, group)
+	}
+
+	addScannedGroup(conf, group)
+
+	return nil
+}
+
+// use zone power limits to guess max value and gradient colors position
+func guessPowercapOptions(zone string, sens *sensor.Sensor) {
+
+	limits := make([]float64, 0)
+
+	files, _ := filepath.Glob(zone + "/constraint_*_power_limit_uw")
+	sort.Strings(files)
+
+	for _, file := range files {
+		if limit, err := readFloat(file); err == nil && limit > 0 {
+			limits = append(limits, limit/sens.Options.Divider)
+		}
+	}
+
+	if len(limits) == 0 {
+		return
+	}
+
+	// max value is the highest (short term) limit
+	for _, limit := range limits {
+		sens.Options.Max = math.Max(sens.Options.Max, limit)
+	}
+
+	// colors break at long term limit
+	sens.Widget.ColorNP = int(limits[0] * 100.0 / sens.Options.Max)
+}
@@ -0,0 +1,154 @@
+package sensor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sensor values filter, i.e. smoothing or spikes rejection
+type Filter interface {
+	Apply(value float64) float64 // feed next value, get filtered one
+}
+
+// consecutive out of range values to accept as a new level by "delta" filter
+const DELTA_FILTER_ACCEPT = 3
+
+// make filters chain of space separated "name:param" list, i.e. "delta:5000 median:5 ewma:0.3":
+// average:N - moving average of N values
+// ewma:A    - exponentially weighted moving average, 0 < A <= 1, lower is smoother
+// median:N  - median of N values, removes single spikes
+// delta:D   - drops values differing from previous one more than D,
+// unless they repeat DELTA_FILTER_ACCEPT times (the value really has changed)
+func NewFilter(spec string) (Filter, error) {
+
+	chain := make(filterChain, 0)
+
+	for _, word := range strings.Fields(spec) {
+
+		name, param, _ := strings.Cut(word, ":")
+
+		value, err := strconv.ParseFloat(param, 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, fmt.Errorf("filter '%s': invalid parameter '%s'", name, param)
+		}
+
+		switch name {
+		case "average", "median":
+			if value < 1 || value > 1000 || value != math.Trunc(value) {
+				return nil, fmt.Errorf("filter '%s': size must be 1..1000", name)
+			}
+			if name == "average" {
+				chain = append(chain, &averageFilter{size: int(value)})
+			} else {
+				chain = append(chain, &medianFilter{size: int(value)})
+			}
+		case "ewma":
+			if value <= 0 || value > 1 {
+				return nil, fmt.Errorf("filter '%s': alpha must be 0..1", name)
+			}
+			chain = append(chain, &ewmaFilter{alpha: value})
+		case "delta":
+			if value <= 0 {
+				return nil, fmt.Errorf("filter '%s': delta must be positive", name)
+			}
+			chain = append(chain, &deltaFilter{delta: value})
+		default:
+			return nil, fmt.Errorf("unknown filter '%s'", name)
+		}
+	}
+
+	return chain, nil
+}
+
+// filters applied one by one
+type filterChain []Filter
+
+func (fc filterChain) Apply(value float64) float64 {
+	for _, f := range fc {
+		value = f.Apply(value)
+	}
+	return value
+}
+
+// moving average
+type averageFilter struct {
+	size   int
+	values []float64
+	sum    float64
+}
+
+func (f *averageFilter) Apply(value float64) float64 {
+	f.values = append(f.values, value)
+	f.sum += value
+	if len(f.values) > f.size {
+		f.sum -= f.values[0]
+		f.values = f.values[1:]
+	}
+	return f.sum / float64(len(f.values))
+}
+
+// exponentially weighted moving average
+type ewmaFilter struct {
+	alpha float64
+	value float64
+	valid bool
+}
+
+func (f *ewmaFilter) Apply(value float64) float64 {
+	if !f.valid {
+		f.value, f.valid = value, true
+	} else {
+		f.value += f.alpha * (value - f.value)
+	}
+	return f.value
+}
+
+// median of last values
+type medianFilter struct {
+	size   int
+	values []float64
+}
+
+func (f *medianFilter) Apply(value float64) float64 {
+
+	f.values = append(f.values, value)
+	if len(f.values) > f.size {
+		f.values = f.values[1:]
+	}
+
+	sorted := make([]float64, len(f.values))
+	copy(sorted, f.values)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2.0
+}
+
+// outliers rejection
+type deltaFilter struct {
+	delta    float64
+	value    float64 // last accepted value
+	valid    bool
+	rejected int // consecutive rejected values
+}
+
+func (f *deltaFilter) Apply(value float64) float64 {
+
+	if !f.valid || math.Abs(value-f.value) <= f.delta {
+		f.value, f.valid, f.rejected = value, true, 0
+		return f.value
+	}
+
+	f.rejected++
+	if f.rejected >= DELTA_FILTER_ACCEPT {
+		f.value, f.rejected = value, 0
+	}
+
+	return f.value
+}
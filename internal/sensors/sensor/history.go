@@ -0,0 +1,139 @@
+package sensor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// default sensors history length and resolution, in seconds
+const (
+	HISTORY_DURATION   = 600
+	HISTORY_RESOLUTION = 5
+)
+
+// sensors history length and resolution, set by SetHistory()
+var (
+	historyDuration   = HISTORY_DURATION * time.Second
+	historyResolution = HISTORY_RESOLUTION * time.Second
+)
+
+// set new sensors history length and resolution (in seconds), used by sensors started after that,
+// zero values mean defaults, negative duration disables the history
+func SetHistory(duration int, resolution int) {
+
+	if duration < 0 {
+		duration = 0
+	} else if duration == 0 {
+		duration = HISTORY_DURATION
+	}
+
+	if resolution <= 0 {
+		resolution = HISTORY_RESOLUTION
+	}
+
+	historyDuration = time.Duration(duration) * time.Second
+	historyResolution = time.Duration(resolution) * time.Second
+}
+
+// timestamped sensor value
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// ring buffer of sensor values, one (averaged) sample per resolution period
+type History struct {
+	duration   time.Duration
+	resolution time.Duration
+	samples    []Sample // ring buffer
+	head       int      // next sample index
+	count      int      // samples in buffer
+	sum        float64  // sum of the last period values
+	num        int      // number of the last period values
+}
+
+func NewHistory(duration time.Duration, resolution time.Duration) *History {
+
+	size := int(duration / resolution)
+	if size < 1 {
+		size = 1
+	}
+
+	return &History{
+		duration:   duration,
+		resolution: resolution,
+		samples:    make([]Sample, size),
+	}
+}
+
+// add the value, values of the same period are averaged
+func (h *History) Add(t time.Time, value float64) {
+
+	t = t.Truncate(h.resolution)
+
+	if h.count > 0 {
+		last := &h.samples[(h.head+len(h.samples)-1)%len(h.samples)]
+		if last.Time.Equal(t) {
+			h.sum += value
+			h.num++
+			last.Value = h.sum / float64(h.num)
+			return
+		}
+	}
+
+	h.samples[h.head] = Sample{Time: t, Value: value}
+	h.head = (h.head + 1) % len(h.samples)
+	if h.count < len(h.samples) {
+		h.count++
+	}
+
+	h.sum, h.num = value, 1
+}
+
+// samples of the last duration period, oldest first
+func (h *History) Samples() []Sample {
+
+	samples := make([]Sample, 0, h.count)
+	if h.count == 0 {
+		return samples
+	}
+
+	first := h.head - h.count + len(h.samples)
+	since := h.samples[(h.head+len(h.samples)-1)%len(h.samples)].Time.Add(-h.duration)
+
+	for i := 0; i < h.count; i++ {
+		s := h.samples[(first+i)%len(h.samples)]
+		// drop samples made before the sensor was offline for a while
+		if s.Time.After(since) {
+			samples = append(samples, s)
+		}
+	}
+
+	return samples
+}
+
+// make svg polyline points of the samples scaled to min..max range into 100x20 box
+func (h *History) Sparkline(min float64, max float64) string {
+
+	samples := h.Samples()
+	if len(samples) < 2 || max <= min {
+		return ""
+	}
+
+	end := samples[len(samples)-1].Time
+	points := make([]string, 0, len(samples))
+
+	for _, s := range samples {
+		x := 100.0 - float64(end.Sub(s.Time))*100.0/float64(h.duration)
+		y := 20.0 - (s.Value-min)*20.0/(max-min)
+		if y < 0.0 {
+			y = 0.0
+		} else if y > 20.0 {
+			y = 20.0
+		}
+		points = append(points, fmt.Sprintf("%.2f,%.2f", x, y))
+	}
+
+	return strings.Join(points, " ")
+}
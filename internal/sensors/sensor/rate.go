@@ -0,0 +1,61 @@
+package sensor
+
+import (
+	"time"
+)
+
+// converts monotonically increasing counter values into per second rates
+type Rate struct {
+	Wrap  float64   // counter wraps around after this value, 0 if unknown
+	last  float64   // last counter value
+	when  time.Time // last counter value timestamp
+	rate  float64   // last calculated rate
+	valid bool      // last counter value is valid
+	ready bool      // at least one rate was calculated
+}
+
+// feed next counter value, returns current rate and whether it's calculated from this value
+// (first value, counter reset or zero time delta give previous rate back)
+func (r *Rate) Update(value float64, now time.Time) (float64, bool) {
+
+	if !r.valid {
+		r.Reset(value, now)
+		return r.rate, false
+	}
+
+	elapsed := now.Sub(r.when).Seconds()
+	if elapsed <= 0.0 {
+		return r.rate, false
+	}
+
+	delta := value - r.last
+	if delta < 0.0 {
+		if r.Wrap > 0.0 && r.last <= r.Wrap {
+			// counter wrapped around
+			delta = r.Wrap - r.last + value
+		} else {
+			// counter was reset, start over
+			r.Reset(value, now)
+			return r.rate, false
+		}
+	}
+
+	r.last = value
+	r.when = now
+	r.rate = delta / elapsed
+	r.ready = true
+
+	return r.rate, true
+}
+
+// is there any rate calculated yet?
+func (r *Rate) Ready() bool {
+	return r.ready
+}
+
+// start counting from this value
+func (r *Rate) Reset(value float64, now time.Time) {
+	r.last = value
+	r.when = now
+	r.valid = true
+}
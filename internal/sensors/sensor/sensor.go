@@ -3,17 +3,41 @@ package sensor
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
-	"os"
-	"strconv"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/maxb-odessa/nonsens/internal/expr"
 	"github.com/maxb-odessa/nonsens/internal/utils"
 	"github.com/maxb-odessa/slog"
 )
 
+const (
+	KIND_TEXT = "text" // sensor shows text state instead of a number
+)
+
+// what to do when the value is out of configured min/max range
+const (
+	RANGE_EXPAND = "expand" // expand runtime range to the value (default)
+	RANGE_LEARN  = "learn"  // expand runtime range, then let it decay back to configured one
+	RANGE_CLAMP  = "clamp"  // clamp the value to configured range
+	RANGE_FIXED  = "fixed"  // keep the value and configured range as is
+)
+
+// default learned range decay half-life, in seconds
+const RANGE_DECAY = 3600
+
+// text sensor state: how to show some value
+type State struct {
+	Value string `json:"value"` // text read from the sensor
+	Label string `json:"label"` // text to show instead, value is shown if empty
+	Color string `json:"color"` // badge color
+}
+
 // config data read from file
 type Sensor struct {
 
@@ -21,12 +45,23 @@ type Sensor struct {
 	pvt struct {
 		sync.Mutex
 		active         bool
-		done           chan bool // sensor is done
-		cancelFunc     func()    // ctx cancelling func
-		id             string    // uniq id
-		input          string    // full path to sensor input file, may vary across reboots
-		fractionsRatio float64   // calculated fractions ratio to be shown
-		percentier     float64   // calculated (max - min ) * 100
+		done           chan bool       // sensor is done
+		trigger        chan bool       // update sensor now
+		cancelFunc     func()          // ctx cancelling func
+		id             string          // uniq id
+		source         Source          // sensor data source, set up by SetSource()
+		rate           Rate            // counter to rate converter for "rate" mode
+		fractionsRatio float64         // calculated fractions ratio to be shown
+		percentier     float64         // calculated (max - min ) * 100
+		exceeded       map[string]bool // currently exceeded thresholds
+		compute        *expr.Expr      // parsed Options.Compute
+		computeErr     error           // Options.Compute parsing error
+		table          *Table          // Options.Table lookup
+		tableErr       error           // Options.Table error
+		filter         Filter          // Options.Filter chain
+		filterErr      error           // Options.Filter error
+		rangeTime      time.Time       // last runtime range update time
+		history        *History        // recent values, nil if disabled
 	} `json:"-"`
 
 	// runtime data, not for save
@@ -35,6 +70,13 @@ type Sensor struct {
 		Value        float64 // current read value
 		Percents     float64 // calculated percents (based on Value and Min/Max)
 		AntiPercents float64 // = (100 - percents) used for gauges
+		Text         string  // current text state, for "text" kind sensors
+		TextColor    string  // current text state badge color
+		Reason       string  // why the sensor is offline
+		Alarm        string  // raised hardware alarms, comma separated
+		Exceeded     string  // exceeded thresholds, comma separated
+		Min          float64 // effective min value, differs from configured one if expanded or learned
+		Max          float64 // effective max value, differs from configured one if expanded or learned
 	} `json:"-"`
 
 	// configured data
@@ -42,12 +84,51 @@ type Sensor struct {
 	Offline bool   `json:"offline"` // is offline?
 
 	Options struct {
+		Type    string  `json:"type"`    // sensor source type, i.e. "hwmon" (default)
+		Kind    string  `json:"kind"`    // sensor value kind: numeric (default) or "text"
 		Device  string  `json:"device"`  // device id as in /sys/devices/..., i.e. 0000:09:00.0
-		Input   string  `json:"input"`   // short input data file name relative to /sys/class/hwmon/hwmonX/
+		Input   string  `json:"input"`   // short input data file name relative to /sys/class/hwmon/hwmonX/ (full path for "file" type)
 		Min     float64 `json:"min"`     // min value
 		Max     float64 `json:"max"`     // max value
 		Divider float64 `json:"divider"` // value divider, i.e. 1000 for temperature values like 42123 which 42.123 deg
+		Rate    bool    `json:"rate"`    // input is a counter, show its change per second, i.e. energy uJ -> Watts
 		Poll    int     `json:"poll"`    // poll interval, in milliseconds
+
+		// calibration, applied after divider in this order:
+		Compute    string       `json:"compute,omitempty"`    // expression, '@' is the value, i.e. "(@ * 2) - 0.5"
+		Table      [][2]float64 `json:"table,omitempty"`      // lookup table of [input, output] points, i.e. thermistor curve
+		Multiplier float64      `json:"multiplier,omitempty"` // value multiplier, i.e. (R1+R2)/R2 of voltage divider, 0 means 1
+		Offset     float64      `json:"offset,omitempty"`     // value offset, i.e. -2.5 for temperature diode error
+
+		// out of range values policy (RANGE_*) and learned range decay half-life, in seconds
+		Range      string `json:"range,omitempty"`
+		RangeDecay int    `json:"rangedecay,omitempty"`
+
+		// values filters chain, applied after calibration, i.e. "delta:5000 median:5" (see NewFilter())
+		Filter string `json:"filter,omitempty"`
+
+		// alarm and fault flag files relative to sensor dir, i.e. temp1_crit_alarm, fan1_fault
+		Alarms []string `json:"alarms,omitempty"`
+
+		// named value limits, i.e. "crit": 95.0, "lcrit": 0.5,
+		// "X_hyst" is the level exceeded limit "X" is cleared at
+		Thresholds map[string]float64 `json:"thresholds,omitempty"`
+
+		// "text" kind sensor options
+		States []State `json:"states,omitempty"` // known states labels and colors
+
+		// short name to refer the sensor value in computed sensors expressions, i.e. "tdie"
+		Alias string `json:"alias,omitempty"`
+
+		// "computed" sensor type options
+		Expression string `json:"expression,omitempty"` // expression of other sensors aliases, i.e. "max(core0..7)" or "tdie - ambient"
+
+		// "exec" sensor type options
+		Command  string   `json:"command,omitempty"`  // command to run, its stdout provides the value
+		Args     []string `json:"args,omitempty"`     // command arguments
+		Timeout  int      `json:"timeout,omitempty"`  // command run timeout, in milliseconds (default is poll interval)
+		Match    string   `json:"match,omitempty"`    // regex to extract the value from stdout, 1-st subexpression is used if any
+		JsonPath string   `json:"jsonpath,omitempty"` // dot-separated path to the value in json stdout, i.e. "gpus.0.temp"
 	} `json:"options"`
 
 	Widget struct {
@@ -71,6 +152,13 @@ func (s *Sensor) Id() string {
 	return s.pvt.id
 }
 
+// persistent sensor identity, unlike Id() it is the same over restarts
+func (s *Sensor) Key() string {
+	parts := []string{s.Options.Type, s.Options.Device, s.Options.Input, s.Options.Command, s.Options.Expression}
+	parts = append(parts, s.Options.Args...)
+	return strings.Join(parts, "|")
+}
+
 func (s *Sensor) SetId(i string) {
 	s.pvt.id = i
 }
@@ -83,8 +171,17 @@ func (s *Sensor) Unlock() {
 	s.pvt.Unlock()
 }
 
-func (s *Sensor) SetInput(i string) {
-	s.pvt.input = i
+// replace sensor data source, previous one (if any) is closed
+func (s *Sensor) SetSource(src Source) {
+	if s.pvt.source != nil && s.pvt.source != src {
+		s.pvt.source.Close()
+	}
+	s.pvt.source = src
+}
+
+// release sensor data source, sensor must be stopped
+func (s *Sensor) Close() {
+	s.SetSource(nil)
 }
 
 func (s *Sensor) Active() bool {
@@ -94,6 +191,7 @@ func (s *Sensor) Active() bool {
 func (s *Sensor) Prepare() {
 	s.pvt.id = utils.MakeUID()
 	s.pvt.done = make(chan bool, 0)
+	s.pvt.trigger = make(chan bool, 1)
 }
 
 func (s *Sensor) SetDefaults() {
@@ -138,67 +236,76 @@ func (sens *Sensor) Start(sensChan chan *Sensor) error {
 		slog.Info("Forcing sensor '%s' min/max to %f/%f", sens.Name, sens.Options.Min, sens.Options.Max)
 	}
 
-	sens.pvt.percentier = (sens.Options.Max - sens.Options.Min) / 100.0
-
-	sens.Name = sens.Options.Device + "/" + sens.Options.Input
-
-	updater := func() {
-
-		// misconfigured sensor?
-		if sens.pvt.input == "" {
-			sens.Offline = true
-		} else if data, err := os.ReadFile(sens.pvt.input); err != nil {
-			sens.Offline = true
-		} else {
-
-			s := strings.TrimSpace(string(data))
-
-			if value, err := strconv.ParseFloat(s, 64); err != nil {
-				sens.Offline = true
-			} else {
+	switch sens.Options.Range {
+	case "", RANGE_EXPAND, RANGE_LEARN, RANGE_CLAMP, RANGE_FIXED:
+	default:
+		slog.Info("Forcing sensor '%s' range policy to '%s'", sens.Name, RANGE_EXPAND)
+		sens.Options.Range = RANGE_EXPAND
+	}
 
-				sens.Lock()
+	// start with configured range, forget learned one
+	sens.Runtime.Min = sens.Options.Min
+	sens.Runtime.Max = sens.Options.Max
+	sens.pvt.percentier = (sens.Runtime.Max - sens.Runtime.Min) / 100.0
+	sens.pvt.rangeTime = time.Time{}
+
+	// start counting rate and checking thresholds and alarms from scratch
+	sens.pvt.exceeded = nil
+	sens.Runtime.Exceeded = ""
+	sens.Runtime.Alarm = ""
+	sens.pvt.rate = Rate{}
+	if w, ok := sens.pvt.source.(Wrapper); ok {
+		sens.pvt.rate.Wrap = w.Wrap()
+	}
 
-				// this senseor is operational
-				sens.Offline = false
+	if sens.pvt.source != nil {
+		sens.Name = sens.pvt.source.Describe()
+	} else {
+		sens.Name = sens.Options.Device + "/" + sens.Options.Input
+	}
 
-				// apply divider if defined
-				if sens.Options.Divider != 1.0 {
-					sens.Runtime.Value = value / sens.Options.Divider
-				} else {
-					sens.Runtime.Value = value
-				}
+	sens.pvt.compute, sens.pvt.computeErr = nil, nil
+	if sens.Options.Compute != "" {
+		if sens.pvt.compute, sens.pvt.computeErr = expr.Parse(sens.Options.Compute); sens.pvt.computeErr != nil {
+			slog.Warn("Invalid sensor '%s' compute expression '%s': %s", sens.Name, sens.Options.Compute, sens.pvt.computeErr)
+		}
+	}
 
-				// round to fractions if defined
-				if sens.Widget.Fractions > 0 {
-					sens.Runtime.Value = math.Round(sens.Runtime.Value*sens.pvt.fractionsRatio) / sens.pvt.fractionsRatio
-				} else {
-					sens.Runtime.Value = math.Round(sens.Runtime.Value)
-				}
+	sens.pvt.table, sens.pvt.tableErr = nil, nil
+	if len(sens.Options.Table) > 0 {
+		if sens.pvt.table, sens.pvt.tableErr = NewTable(sens.Options.Table); sens.pvt.tableErr != nil {
+			slog.Warn("Invalid sensor '%s' lookup table: %s", sens.Name, sens.pvt.tableErr)
+		}
+	}
 
-				// auto-adjust min/max values
-				if sens.Runtime.Value > sens.Options.Max {
-					slog.Warn("Max value for sensor '%s' is too low: value=%f, max=%f), adjusting", sens.Name, sens.Runtime.Value, sens.Options.Max)
-					sens.Options.Max = sens.Runtime.Value
-					sens.pvt.percentier = (sens.Options.Max - sens.Options.Min) / 100.0
-				}
+	// start collecting history from scratch: value meaning may have changed
+	sens.pvt.history = nil
+	if historyDuration > 0 && sens.Options.Kind != KIND_TEXT {
+		sens.pvt.history = NewHistory(historyDuration, historyResolution)
+	}
 
-				if sens.Runtime.Value < sens.Options.Min {
-					slog.Warn("Min value for sensor '%s' is too high: value=%f, min=%f), adjusting", sens.Name, sens.Runtime.Value, sens.Options.Min)
-					sens.Options.Min = sens.Runtime.Value
-					sens.pvt.percentier = (sens.Options.Max - sens.Options.Min) / 100.0
-				}
+	// start filtering from scratch
+	if sens.pvt.filter, sens.pvt.filterErr = NewFilter(sens.Options.Filter); sens.pvt.filterErr != nil {
+		slog.Warn("Invalid sensor '%s' filter: %s", sens.Name, sens.pvt.filterErr)
+	}
 
-				// calc percents
-				sens.Runtime.Percents = (sens.Runtime.Value - sens.Options.Min) / sens.pvt.percentier
-				sens.Runtime.AntiPercents = 100.0 - sens.Runtime.Percents
+	updater := func() {
+		var err error
 
-				sens.Unlock()
+		if sens.Options.Kind == KIND_TEXT {
+			err = sens.updateText()
+		} else {
+			err = sens.updateValue()
+		}
 
-				slog.Debug(5, "sensor '%s' value=%f percents=%f", sens.Name, sens.Runtime.Value, sens.Runtime.Percents)
-			}
+		if err != nil {
+			slog.Debug(5, "sensor '%s' read failed: %s", sens.Name, err)
+			sens.Offline = true
+			sens.Runtime.Reason = utils.SafeHTML(err.Error())
 		}
 
+		sens.updateAlarms()
+
 		select {
 		case sensChan <- sens:
 		default:
@@ -214,6 +321,12 @@ func (sens *Sensor) Start(sensChan chan *Sensor) error {
 		interval := time.Duration(sens.Options.Poll)
 		ticker := time.NewTicker(interval * time.Millisecond)
 
+		// on demand sensors are not polled
+		tick := ticker.C
+		if t, ok := sens.pvt.source.(Triggered); ok && t.Triggered() {
+			tick = nil
+		}
+
 		defer func() {
 			slog.Info("Stopped sensor '%s'", sens.Name)
 			ticker.Stop()
@@ -231,7 +344,9 @@ func (sens *Sensor) Start(sensChan chan *Sensor) error {
 			case <-ctx.Done():
 				loop = false
 				break
-			case <-ticker.C:
+			case <-tick:
+				updater()
+			case <-sens.pvt.trigger:
 				updater()
 			}
 		}
@@ -241,6 +356,321 @@ func (sens *Sensor) Start(sensChan chan *Sensor) error {
 	return nil
 }
 
+// read sensor numeric value and calculate all the derived values
+func (sens *Sensor) updateValue() error {
+
+	value, err := sens.read()
+	if err != nil {
+		return err
+	}
+
+	// apply divider if defined
+	if sens.Options.Divider != 1.0 {
+		value /= sens.Options.Divider
+	}
+
+	// apply compute expression if defined
+	if sens.pvt.computeErr != nil {
+		return fmt.Errorf("invalid compute expression: %s", sens.pvt.computeErr)
+	} else if sens.pvt.compute != nil {
+		if value, err = sens.pvt.compute.Eval(value, nil); err != nil {
+			return fmt.Errorf("compute failed: %s", err)
+		}
+	}
+
+	// apply lookup table if defined
+	if sens.pvt.tableErr != nil {
+		return fmt.Errorf("invalid lookup table: %s", sens.pvt.tableErr)
+	} else if sens.pvt.table != nil {
+		value = sens.pvt.table.Lookup(value)
+	}
+
+	// apply multiplier and offset if defined
+	if sens.Options.Multiplier != 0.0 {
+		value *= sens.Options.Multiplier
+	}
+	value += sens.Options.Offset
+
+	// smooth the value and drop spikes
+	if sens.pvt.filterErr != nil {
+		return fmt.Errorf("invalid filter: %s", sens.pvt.filterErr)
+	}
+	value = sens.pvt.filter.Apply(value)
+
+	sens.Lock()
+
+	// this senseor is operational
+	sens.Offline = false
+	sens.Runtime.Reason = ""
+
+	sens.Runtime.Value = value
+
+	// round to fractions if defined
+	if sens.Widget.Fractions > 0 {
+		sens.Runtime.Value = math.Round(sens.Runtime.Value*sens.pvt.fractionsRatio) / sens.pvt.fractionsRatio
+	} else {
+		sens.Runtime.Value = math.Round(sens.Runtime.Value)
+	}
+
+	sens.updateRange()
+
+	sens.checkThresholds()
+
+	// calc percents, out of range values are shown as 0% or 100%
+	sens.Runtime.Percents = (sens.Runtime.Value - sens.Runtime.Min) / sens.pvt.percentier
+	sens.Runtime.Percents = math.Max(0.0, math.Min(100.0, sens.Runtime.Percents))
+	sens.Runtime.AntiPercents = 100.0 - sens.Runtime.Percents
+
+	if sens.pvt.history != nil {
+		sens.pvt.history.Add(time.Now(), sens.Runtime.Value)
+	}
+
+	sens.Unlock()
+
+	slog.Debug(5, "sensor '%s' value=%f percents=%f", sens.Name, sens.Runtime.Value, sens.Runtime.Percents)
+
+	return nil
+}
+
+// apply range policy to the value, log runtime range changes, sensor must be locked
+func (sens *Sensor) updateRange() {
+
+	now := time.Now()
+	elapsed := now.Sub(sens.pvt.rangeTime).Seconds()
+	if sens.pvt.rangeTime.IsZero() {
+		elapsed = 0.0
+	}
+	sens.pvt.rangeTime = now
+
+	value := sens.Runtime.Value
+	min, max := sens.Runtime.Min, sens.Runtime.Max
+
+	switch sens.Options.Range {
+
+	case RANGE_FIXED:
+		return
+
+	case RANGE_CLAMP:
+		if value > sens.Options.Max || value < sens.Options.Min {
+			slog.Debug(5, "sensor '%s' value %f is out of range %f..%f, clamping", sens.Name, value, sens.Options.Min, sens.Options.Max)
+			sens.Runtime.Value = math.Max(sens.Options.Min, math.Min(sens.Options.Max, value))
+		}
+		return
+
+	case RANGE_LEARN:
+		// learned range shrinks back to configured one (but not beyond the value) by half every decay period
+		decay := sens.Options.RangeDecay
+		if decay <= 0 {
+			decay = RANGE_DECAY
+		}
+		k := 1.0 - math.Pow(0.5, elapsed/float64(decay))
+
+		if target := math.Max(sens.Options.Max, value); max > target {
+			if max -= (max - target) * k; max-target < sens.pvt.percentier/100.0 {
+				max = target
+			}
+		}
+
+		if target := math.Min(sens.Options.Min, value); min < target {
+			if min += (target - min) * k; target-min < sens.pvt.percentier/100.0 {
+				min = target
+			}
+		}
+
+		if (min != sens.Runtime.Min || max != sens.Runtime.Max) && min == sens.Options.Min && max == sens.Options.Max {
+			slog.Info("Sensor '%s' range is back to configured %f..%f", sens.Name, min, max)
+		}
+	}
+
+	// expand the range to the value
+	if value > max {
+		slog.Warn("Max value for sensor '%s' is too low: value=%f, max=%f, expanding (configured max=%f)", sens.Name, value, max, sens.Options.Max)
+		max = value
+	}
+
+	if value < min {
+		slog.Warn("Min value for sensor '%s' is too high: value=%f, min=%f, expanding (configured min=%f)", sens.Name, value, min, sens.Options.Min)
+		min = value
+	}
+
+	sens.Runtime.Min, sens.Runtime.Max = min, max
+	sens.pvt.percentier = (max - min) / 100.0
+}
+
+// read sensor text state
+func (sens *Sensor) updateText() error {
+
+	// misconfigured sensor?
+	if sens.pvt.source == nil {
+		return errors.New("not configured")
+	}
+
+	tr, ok := sens.pvt.source.(TextReader)
+	if !ok {
+		return errors.New("text is not supported")
+	}
+
+	text, err := tr.ReadText()
+	if err != nil {
+		return err
+	}
+
+	sens.Lock()
+
+	// this senseor is operational
+	sens.Offline = false
+	sens.Runtime.Reason = ""
+	sens.Runtime.Text = utils.SafeHTML(text)
+	sens.Runtime.TextColor = "" // default badge color
+
+	// apply state label and color if known
+	for _, st := range sens.Options.States {
+		if strings.EqualFold(st.Value, text) {
+			if st.Label != "" {
+				sens.Runtime.Text = utils.SafeHTML(st.Label)
+			}
+			if st.Color != "" {
+				sens.Runtime.TextColor = st.Color
+			}
+			break
+		}
+	}
+
+	sens.Unlock()
+
+	slog.Debug(5, "sensor '%s' text=%s", sens.Name, text)
+
+	return nil
+}
+
+// lower limits are exceeded when value drops below them, i.e. "lcrit" or "min"
+func IsLowThreshold(name string) bool {
+	return strings.HasPrefix(name, "l") || strings.HasPrefix(name, "min")
+}
+
+// find exceeded thresholds, log the changes, sensor must be locked
+func (sens *Sensor) checkThresholds() {
+
+	if len(sens.Options.Thresholds) == 0 {
+		sens.Runtime.Exceeded = ""
+		return
+	}
+
+	names := make([]string, 0, len(sens.Options.Thresholds))
+	for name := range sens.Options.Thresholds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if sens.pvt.exceeded == nil {
+		sens.pvt.exceeded = make(map[string]bool)
+	}
+
+	exceeded := make([]string, 0)
+	for _, name := range names {
+
+		if strings.HasSuffix(name, "_hyst") {
+			continue
+		}
+
+		// once exceeded, the limit holds until the value crosses its hysteresis level
+		limit := sens.Options.Thresholds[name]
+		if hyst, ok := sens.Options.Thresholds[name+"_hyst"]; ok && sens.pvt.exceeded[name] {
+			limit = hyst
+		}
+
+		low := IsLowThreshold(name)
+		sens.pvt.exceeded[name] = (low && sens.Runtime.Value <= limit) || (!low && sens.Runtime.Value >= limit)
+
+		if sens.pvt.exceeded[name] {
+			exceeded = append(exceeded, name)
+		}
+	}
+
+	ex := utils.SafeHTML(strings.Join(exceeded, ", "))
+
+	if ex != sens.Runtime.Exceeded {
+		if ex != "" {
+			slog.Warn("Sensor '%s' value %f exceeded threshold: %s", sens.Name, sens.Runtime.Value, ex)
+		} else {
+			slog.Info("Sensor '%s' value %f is within thresholds", sens.Name, sens.Runtime.Value)
+		}
+	}
+
+	sens.Runtime.Exceeded = ex
+}
+
+// check sensor hardware alarm flags, log raised and cleared ones
+func (sens *Sensor) updateAlarms() {
+
+	al, ok := sens.pvt.source.(Alarmer)
+	if !ok || len(sens.Options.Alarms) == 0 {
+		sens.Lock()
+		sens.Runtime.Alarm = ""
+		sens.Unlock()
+		return
+	}
+
+	alarms, err := al.Alarms()
+	if err != nil {
+		slog.Debug(5, "sensor '%s' alarms read failed: %s", sens.Name, err)
+		return
+	}
+
+	alarm := utils.SafeHTML(strings.Join(alarms, ", "))
+
+	if alarm != sens.Runtime.Alarm {
+		if alarm != "" {
+			slog.Warn("Sensor '%s' alarm raised: %s", sens.Name, alarm)
+		} else {
+			slog.Info("Sensor '%s' alarm cleared", sens.Name)
+		}
+	}
+
+	sens.Lock()
+	sens.Runtime.Alarm = alarm
+	sens.Unlock()
+}
+
+// read sensor value from its source, convert it into rate if requested
+func (sens *Sensor) read() (float64, error) {
+
+	// misconfigured sensor?
+	if sens.pvt.source == nil {
+		return 0.0, errors.New("not configured")
+	}
+
+	value, err := sens.pvt.source.Read()
+	if err != nil || !sens.Options.Rate {
+		return value, err
+	}
+
+	value, _ = sens.pvt.rate.Update(value, time.Now())
+
+	// need at least two counter values to calculate the rate
+	if !sens.pvt.rate.Ready() {
+		return 0.0, errors.New("collecting data")
+	}
+
+	return value, nil
+}
+
+// recent sensor values as svg polyline points, empty if there are not enough values, sensor must be locked
+func (s *Sensor) Sparkline() string {
+	if s.pvt.history == nil {
+		return ""
+	}
+	return s.pvt.history.Sparkline(s.Runtime.Min, s.Runtime.Max)
+}
+
+// request sensor update out of poll schedule, i.e. computed sensor input has changed
+func (s *Sensor) Trigger() {
+	select {
+	case s.pvt.trigger <- true:
+	default: // already requested
+	}
+}
+
 func (s *Sensor) Stop() {
 	if s.pvt.active && s.pvt.cancelFunc != nil {
 		s.pvt.cancelFunc()
@@ -0,0 +1,31 @@
+package sensor
+
+// sensor data acquisition back-end, i.e. hwmon input file reader
+type Source interface {
+	Setup(sens *Sensor) error // find sensor input using sensor options
+	Read() (float64, error)   // read current (raw) sensor value
+	Describe() string         // short source description, i.e. "device/input"
+	Close()                   // release resources held by the source
+}
+
+// optional Source interface for counters which wrap around at known value,
+// i.e. powercap energy_uj at max_energy_range_uj
+type Wrapper interface {
+	Wrap() float64 // max counter value, 0 if unknown
+}
+
+// optional Source interface for text state reading, used by "text" kind sensors
+type TextReader interface {
+	ReadText() (string, error) // read current sensor state
+}
+
+// optional Source interface for hardware alarm and fault flags, i.e. hwmon temp1_crit_alarm
+type Alarmer interface {
+	Alarms() ([]string, error) // names of currently raised alarms
+}
+
+// optional Source interface for sources which are not polled but updated on demand
+// (see Sensor.Trigger()), i.e. computed sensors
+type Triggered interface {
+	Triggered() bool // true if sensor is updated on demand only
+}
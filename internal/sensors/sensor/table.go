@@ -0,0 +1,48 @@
+package sensor
+
+import (
+	"errors"
+	"sort"
+)
+
+// calibration lookup table: piecewise linear function of [input, output] points,
+// values beyond the table are extrapolated by its edge segments
+type Table struct {
+	points [][2]float64 // sorted by input
+}
+
+func NewTable(points [][2]float64) (*Table, error) {
+
+	if len(points) < 2 {
+		return nil, errors.New("lookup table needs at least 2 points")
+	}
+
+	t := &Table{points: make([][2]float64, len(points))}
+	copy(t.points, points)
+
+	sort.Slice(t.points, func(i, j int) bool { return t.points[i][0] < t.points[j][0] })
+
+	for i := 1; i < len(t.points); i++ {
+		if t.points[i][0] == t.points[i-1][0] {
+			return nil, errors.New("lookup table has duplicate inputs")
+		}
+	}
+
+	return t, nil
+}
+
+// convert input value using the table
+func (t *Table) Lookup(value float64) float64 {
+
+	// find the segment: first point with greater input, edge segments for out of range values
+	i := sort.Search(len(t.points), func(i int) bool { return t.points[i][0] > value })
+	if i == 0 {
+		i = 1
+	} else if i == len(t.points) {
+		i = len(t.points) - 1
+	}
+
+	p0, p1 := t.points[i-1], t.points[i]
+
+	return p0[1] + (value-p0[0])*(p1[1]-p0[1])/(p1[0]-p0[0])
+}
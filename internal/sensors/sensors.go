@@ -1,19 +1,88 @@
 package sensors
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/maxb-odessa/nonsens/internal/config"
 	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+	"github.com/maxb-odessa/nonsens/internal/storage"
+	"github.com/maxb-odessa/nonsens/internal/utils"
+
+	"github.com/maxb-odessa/slog"
 )
 
-var sensChan chan *sensor.Sensor
+const (
+	DEFAULT_TYPE = "hwmon"
+)
+
+var sensChan chan *sensor.Sensor // sensors push their updates here
+var updChan chan *sensor.Sensor  // sensors updates after computed sensors are handled
+
+// sensor scanners, each one adds groups of found sensors to the config
+var scanners = []struct {
+	name string
+	scan func(conf *config.Config) error
+}{
+	{"hwmon", scanHwmon},
+	{"iio", scanIio},
+	{"thermal", scanThermal},
+	{"cpu", scanCpu},
+	{"meminfo", scanMeminfo},
+	{"net", scanNet},
+	{"disk", scanDisks},
+	{"fs", scanFilesystems},
+	{"powercap", scanPowercap},
+	{"power_supply", scanPowerSupplies},
+	{"drm", scanDrm},
+}
+
+// known sensor sources by sensor type
+var sources = map[string]func() sensor.Source{
+	"hwmon":   func() sensor.Source { return new(hwmonSource) },
+	"exec":    func() sensor.Source { return new(execSource) },
+	"file":    func() sensor.Source { return new(fileSource) },
+	"iio":     func() sensor.Source { return new(iioSource) },
+	"thermal": func() sensor.Source { return new(thermalSource) },
+	"cooling": func() sensor.Source { return new(coolingSource) },
+	"cpu":     func() sensor.Source { return new(cpuSource) },
+	"net":     func() sensor.Source { return new(netSource) },
+	"disk":    func() sensor.Source { return new(diskSource) },
+	"fs":      func() sensor.Source { return new(fsSource) },
+	"drm":     func() sensor.Source { return new(drmSource) },
+	"meminfo": func() sensor.Source { return new(meminfoSource) },
+
+	"power_supply": func() sensor.Source { return new(powerSource) },
+	"computed":     func() sensor.Source { return new(computedSource) },
+}
 
 func Chan() chan *sensor.Sensor {
 	return sensChan
 }
 
+func Updates() chan *sensor.Sensor {
+	return updChan
+}
+
 func Run(conf *config.Config) error {
 
 	sensChan = make(chan *sensor.Sensor, 64)
+	updChan = make(chan *sensor.Sensor, 64)
+
+	go dispatch()
+
+	// chips knowledge base is used for sensors scanning
+	if err := LoadChips(conf.ChipsFile); err != nil {
+		slog.Warn("Failed to load chips file '%s': %s", conf.ChipsFile, err)
+	}
+
+	lmSensorsPath = os.ExpandEnv(conf.LmSensorsConf)
+
+	sensor.SetHistory(conf.HistoryDuration, conf.HistoryResolution)
 
 	// configure sensors via hwmon kernel subsystem
 	if err := setupAllSensors(conf); err != nil {
@@ -25,6 +94,107 @@ func Run(conf *config.Config) error {
 	return nil
 }
 
+// pass sensors updates from sensors channel to updates channel,
+// update computed sensors and store sensors values on the way
+func dispatch() {
+
+	for sens := range sensChan {
+
+		sens.Lock()
+		value := sens.Runtime.Value
+		valid := !sens.Offline && sens.Options.Kind != sensor.KIND_TEXT
+		key := sens.Key()
+		sens.Unlock()
+
+		if valid {
+			storage.Add(key, time.Now(), value)
+		}
+
+		updateComputed(sens, value, valid)
+
+		select {
+		case updChan <- sens:
+		default:
+			slog.Debug(1, "sensors updates queue is full, discarding sensor data")
+		}
+	}
+}
+
+// setup single sensor: make and prepare its data source
+func SetupSensor(sens *sensor.Sensor) bool {
+
+	// alias may be changed or used by another sensor now
+	forgetAlias(sens)
+
+	if sens.Options.Type == "" {
+		sens.Options.Type = DEFAULT_TYPE
+	}
+
+	newSource, ok := sources[sens.Options.Type]
+	if !ok {
+		slog.Warn("Ignoring sensor '%s/%s' of unknown type '%s'", sens.Options.Device, sens.Options.Input, sens.Options.Type)
+		sens.SetSource(nil)
+		return false
+	}
+
+	src := newSource()
+	if err := src.Setup(sens); err != nil {
+		slog.Warn("Failed to setup sensor '%s/%s': %s", sens.Options.Device, sens.Options.Input, err)
+		sens.SetSource(nil)
+		return false
+	}
+
+	sens.SetSource(src)
+	setAlias(sens)
+
+	return true
+}
+
+// release sensor data source and alias, sensor must be stopped
+func CloseSensor(sens *sensor.Sensor) {
+	sens.Close()
+	forgetAlias(sens)
+}
+
+// scan for all available sensors and make a new config of them
+func ScanAllSensors() *config.Config {
+
+	conf := new(config.Config)
+	failed := false
+
+	for _, sc := range scanners {
+		if err := sc.scan(conf); err != nil {
+			slog.Err("Scan of %s sensors failed: %s", sc.name, err)
+			failed = true
+		}
+	}
+
+	// nothing found and something went wrong
+	if failed && len(conf.Columns) == 0 {
+		return nil
+	}
+
+	return conf
+}
+
+// add non-empty scanned group to the last column
+func addScannedGroup(conf *config.Config, group *config.Group) {
+
+	if len(group.Sensors) == 0 {
+		return
+	}
+
+	group.SetId(utils.MakeUID())
+
+	// make new column if current is too long (just for beauty)
+	ci := len(conf.Columns) - 1
+	if ci < 0 || len(conf.Columns[ci].Groups) >= 4 {
+		ci++
+	}
+
+	conf.AddGroup(ci, group)
+}
+
 func StartAllSensors(conf *config.Config) {
 	for _, sens := range conf.AllSensors() {
 		sens.Start(sensChan)
@@ -36,3 +206,49 @@ func StopAllSensors(conf *config.Config) {
 		sens.Stop()
 	}
 }
+
+// stop sensors and release their resources, i.e. before the config is replaced
+func CloseAllSensors(conf *config.Config) {
+	for _, sens := range conf.AllSensors() {
+		sens.Stop()
+		CloseSensor(sens)
+	}
+}
+
+// read trimmed file contents
+func readString(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// read a number from file
+func readFloat(path string) (float64, error) {
+	s, err := readString(path)
+	if err != nil {
+		return 0.0, err
+	}
+	if value, err := strconv.ParseFloat(s, 64); err != nil {
+		// don't show the content, the file may be not what it is expected to be
+		return 0.0, fmt.Errorf("invalid value in '%s'", path)
+	} else {
+		return value, nil
+	}
+}
+
+// find wrap around value of a counter file: powercap energy_uj has max_energy_range_uj beside it
+func counterWrap(path string) float64 {
+
+	dir, name := filepath.Split(path)
+	if !strings.HasSuffix(name, "_uj") {
+		return 0.0
+	}
+
+	if max, err := readFloat(dir + "max_" + strings.TrimSuffix(name, "_uj") + "_range_uj"); err == nil {
+		return max
+	}
+
+	return 0.0
+}
@@ -0,0 +1,297 @@
+package sensors
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/maxb-odessa/nonsens/internal/config"
+	"github.com/maxb-odessa/nonsens/internal/sensors/sensor"
+
+	"github.com/maxb-odessa/slog"
+)
+
+const (
+	THERMAL_PATH = "/sys/class/thermal"
+)
+
+// thermal zone sensor source: reads /sys/class/thermal/thermal_zoneX/temp
+// zone is identified by its type (or by dir name if there are several zones of the same type)
+type thermalSource struct {
+	device string // zone type, i.e. x86_pkg_temp
+	input  string // input file name relative to zone dir
+	path   string // full path to input file
+}
+
+func (ts *thermalSource) Setup(sens *sensor.Sensor) error {
+
+	if sens.Options.Device == "" {
+		return fmt.Errorf("device is not defined")
+	}
+
+	if sens.Options.Input == "" {
+		sens.Options.Input = "temp"
+	}
+
+	ts.device = sens.Options.Device
+	ts.input = sens.Options.Input
+
+	if dir := findThermalDir("thermal_zone", ts.device); dir == "" {
+		return fmt.Errorf("thermal zone not found")
+	} else {
+		sens.Runtime.Dir = dir
+	}
+
+	ts.path = sens.Runtime.Dir + ts.input
+
+	return nil
+}
+
+func (ts *thermalSource) Read() (float64, error) {
+	return readFloat(ts.path)
+}
+
+func (ts *thermalSource) ReadText() (string, error) {
+	return readString(ts.path)
+}
+
+func (ts *thermalSource) Describe() string {
+	return ts.device + "/" + ts.input
+}
+
+func (ts *thermalSource) Close() {
+}
+
+// cooling device sensor source: shows /sys/class/thermal/cooling_deviceX/cur_state
+// as percents of max_state
+type coolingSource struct {
+	device   string  // cooling device type, i.e. Fan
+	path     string  // full path to cur_state file
+	maxState float64 // max cooling state
+}
+
+func (cs *coolingSource) Setup(sens *sensor.Sensor) error {
+	var err error
+
+	if sens.Options.Device == "" {
+		return fmt.Errorf("device is not defined")
+	}
+
+	cs.device = sens.Options.Device
+	sens.Options.Input = "cur_state"
+
+	if dir := findThermalDir("cooling_device", cs.device); dir == "" {
+		return fmt.Errorf("cooling device not found")
+	} else {
+		sens.Runtime.Dir = dir
+	}
+
+	cs.path = sens.Runtime.Dir + "cur_state"
+
+	if cs.maxState, err = readFloat(sens.Runtime.Dir + "max_state"); err != nil {
+		return err
+	} else if cs.maxState <= 0 {
+		return fmt.Errorf("cooling device has no states")
+	}
+
+	return nil
+}
+
+func (cs *coolingSource) Read() (float64, error) {
+	if state, err := readFloat(cs.path); err != nil {
+		return 0.0, err
+	} else {
+		return state * 100.0 / cs.maxState, nil
+	}
+}
+
+func (cs *coolingSource) Describe() string {
+	return cs.device + "/cur_state"
+}
+
+func (cs *coolingSource) Close() {
+}
+
+// find thermal zone or cooling device dir by its type or dir name
+func findThermalDir(prefix string, device string) string {
+
+	dirs, err := os.ReadDir(THERMAL_PATH)
+	if err != nil {
+		slog.Err("Scan of '%s' failed: %s", THERMAL_PATH, err)
+		return ""
+	}
+
+	for _, dir := range dirs {
+		if !strings.HasPrefix(dir.Name(), prefix) {
+			continue
+		}
+		dirName := THERMAL_PATH + "/" + dir.Name() + "/"
+		if dir.Name() == device {
+			return dirName
+		} else if typ, err := readString(dirName + "type"); err == nil && typ == device {
+			return dirName
+		}
+	}
+
+	return ""
+}
+
+// list thermal zones or cooling devices dirs and make uniq device ids for them
+func listThermalDevices(prefix string) (map[string]string, error) {
+
+	dirs, err := os.ReadDir(THERMAL_PATH)
+	if err != nil {
+		return nil, err
+	}
+
+	types := make(map[string]string) // dir name -> type
+	count := make(map[string]int)    // type -> number of devices of this type
+
+	for _, dir := range dirs {
+		if strings.HasPrefix(dir.Name(), prefix) {
+			if typ, err := readString(THERMAL_PATH + "/" + dir.Name() + "/type"); err == nil {
+				types[dir.Name()] = typ
+				count[typ]++
+			}
+		}
+	}
+
+	// use type as device id if it's uniq, dir name otherwise
+	devices := make(map[string]string) // device id -> visible name
+	for name, typ := range types {
+		if count[typ] > 1 {
+			devices[name] = typ + " " + strings.TrimPrefix(name, prefix)
+		} else {
+			devices[typ] = typ
+		}
+	}
+
+	return devices, nil
+}
+
+// scan /sys/class/thermal for thermal zones and cooling devices
+func scanThermal(conf *config.Config) error {
+
+	zones, err := listThermalDevices("thermal_zone")
+	if os.IsNotExist(err) {
+		return nil // no thermal class at all
+	} else if err != nil {
+		return err
+	}
+
+	group := new(config.Group)
+	group.SetName("Thermal zones")
+
+	for _, device := range sortedKeys(zones) {
+
+		se := new(sensor.Sensor)
+		se.Prepare()
+		se.SetDefaults()
+
+		se.Options.Type = "thermal"
+		se.Options.Device = device
+		se.Options.Input = "temp"
+		se.Options.Divider = 1_000.0
+		se.Widget.Name = zones[device]
+		se.Widget.Units = `&deg;C`
+
+		if SetupSensor(se) {
+			guessThermalOptions(se)
+		}
+
+		conf.AddSensor(se, group)
+	}
+
+	addScannedGroup(conf, group)
+
+	coolers, err := listThermalDevices("cooling_device")
+	if err != nil {
+		return err
+	}
+
+	group = new(config.Group)
+	group.SetName("Cooling devices")
+
+	for _, device := range sortedKeys(coolers) {
+
+		se := new(sensor.Sensor)
+		se.Prepare()
+		se.SetDefaults()
+
+		se.Options.Type = "cooling"
+		se.Options.Device = device
+		se.Options.Input = "cur_state"
+		se.Options.Max = 100.0
+		se.Widget.Name = coolers[device]
+		se.Widget.Units = "%"
+		se.Widget.Fractions = 0
+
+		// devices without states are useless
+		if SetupSensor(se) {
+			conf.AddSensor(se, group)
+		}
+	}
+
+	addScannedGroup(conf, group)
+
+	return nil
+}
+
+// use zone trip points to guess max value and gradient colors position
+func guessThermalOptions(sens *sensor.Sensor) {
+
+	files, err := os.ReadDir(sens.Runtime.Dir)
+	if err != nil {
+		return
+	}
+
+	trips := make(map[string]float64) // trip type -> lowest trip temp of that type
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasPrefix(name, "trip_point_") || !strings.HasSuffix(name, "_temp") {
+			continue
+		}
+		temp, err := readFloat(sens.Runtime.Dir + name)
+		if err != nil || temp <= 0 {
+			continue // disabled or invalid trip point
+		}
+		typ, err := readString(sens.Runtime.Dir + strings.TrimSuffix(name, "_temp") + "_type")
+		if err != nil {
+			continue
+		}
+		temp /= sens.Options.Divider
+		if t, ok := trips[typ]; !ok || temp < t {
+			trips[typ] = temp
+		}
+	}
+
+	// max value is the highest trip point
+	for _, temp := range trips {
+		sens.Options.Max = math.Max(sens.Options.Max, temp)
+	}
+
+	if sens.Options.Max == 0.0 {
+		return
+	}
+
+	slog.Info("Using Max value '%f' for sensor '%s'", sens.Options.Max, sens.Options.Device)
+
+	// colors break at the first trip point which makes system react
+	for _, typ := range []string{"passive", "active", "hot"} {
+		if temp, ok := trips[typ]; ok && temp < sens.Options.Max {
+			sens.Widget.ColorNP = int(temp * 100.0 / sens.Options.Max)
+			break
+		}
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
 
 	"github.com/maxb-odessa/nonsens/internal/config"
 	"github.com/maxb-odessa/nonsens/internal/sensors"
@@ -41,6 +42,9 @@ func processFeedback(data []byte) {
 	}
 	slog.Debug(9, "GOT MSG: %+v", msg)
 
+	confLock.Lock()
+	defer confLock.Unlock()
+
 	if msg.Sensor != nil {
 		// modify sensor
 		needRefresh = modifySensor(msg.Id, msg.Action, msg.Sensor)
@@ -65,7 +69,7 @@ func processFeedback(data []byte) {
 			sendInfo("Scanning for sernsors...")
 			newConf := sensors.ScanAllSensors()
 			if newConf != nil {
-				sensors.StopAllSensors(conf)
+				sensors.CloseAllSensors(conf)
 				newConf.ImportServerData(conf)
 				conf = newConf
 				sensors.StartAllSensors(conf)
@@ -74,9 +78,25 @@ func processFeedback(data []byte) {
 			} else {
 				sendInfo("Scan failed...")
 			}
+		// apply lm-sensors config to current sensors
+		case "lmsensors":
+			if changed, err := sensors.ApplyLmSensors(conf); err != nil {
+				errMsg := fmt.Sprintf("lm-sensors config apply failed: %s", err)
+				slog.Err(errMsg)
+				sendInfo(errMsg)
+			} else {
+				needRefresh = true
+				sendInfo(fmt.Sprintf("lm-sensors config applied, %d sensors changed", changed))
+			}
 		case "restore":
 			if conf != confBackup {
+				// sensors of the replaced config are closed, set them up again
+				sensors.CloseAllSensors(conf)
 				conf = confBackup
+				for _, se := range conf.AllSensors() {
+					sensors.SetupSensor(se)
+				}
+				sensors.StartAllSensors(conf)
 				needRefresh = true
 				sendInfo("Configuration restored!")
 			} else {
@@ -103,6 +123,9 @@ func modifySensor(id string, action string, sData *SensorData) bool {
 
 	// add new sensor
 	if action == "new" {
+		if execDenied(nil, sData.Sensor) {
+			return false
+		}
 		sData.Sensor.Prepare()
 		_, _, gr := conf.FindGroupById(sData.GroupId)
 		conf.AddSensor(sData.Sensor, gr)
@@ -121,16 +144,31 @@ func modifySensor(id string, action string, sData *SensorData) bool {
 
 	if action == "remove" {
 		se.Stop()
+		sensors.CloseSensor(se)
 		conf.RemoveSensor(se)
 		slog.Info("Removed sensor '%s'", se.Name)
 		return true
 	}
 
+	// stop it first: its poll goroutine needs the lock to finish
+	se.Stop()
+
 	se.Lock()
-	defer se.Unlock()
 
-	// device or input file changed - reconfig sensors
-	if se.Options.Device != sData.Sensor.Options.Device || se.Options.Input != sData.Sensor.Options.Input {
+	// commands are not shown in the editor, keep them
+	if sData.Sensor.Options.Command == "" && len(sData.Sensor.Options.Args) == 0 {
+		sData.Sensor.Options.Command = se.Options.Command
+		sData.Sensor.Options.Args = se.Options.Args
+	}
+
+	if execDenied(se, sData.Sensor) {
+		se.Unlock()
+		se.Start(sensors.Chan())
+		return false
+	}
+
+	// sensor options changed - reconfig sensors
+	if !reflect.DeepEqual(se.Options, sData.Sensor.Options) {
 		needReconfig = true
 	}
 
@@ -140,6 +178,8 @@ func modifySensor(id string, action string, sData *SensorData) bool {
 	se.Options = sData.Sensor.Options
 	se.Widget = sData.Sensor.Widget
 
+	se.Unlock()
+
 	// group changed
 	if gr.Id() != sData.GroupId {
 		conf.MoveSensorToGroup(se, gr, sData.GroupId)
@@ -150,8 +190,6 @@ func modifySensor(id string, action string, sData *SensorData) bool {
 		conf.MoveSensorToGroupTop(se)
 	}
 
-	se.Stop()
-
 	if needReconfig {
 		sensors.SetupSensor(se)
 	}
@@ -161,6 +199,25 @@ func modifySensor(id string, action string, sData *SensorData) bool {
 	return true
 }
 
+// "exec" sensors run commands as the service user, so unless allowed in server config
+// they may be defined in config file only: deny new ones and their commands changes
+func execDenied(oldSe *sensor.Sensor, newSe *sensor.Sensor) bool {
+
+	if conf.Server.ExecSensors || newSe.Options.Type != "exec" {
+		return false
+	}
+
+	if oldSe != nil && oldSe.Options.Type == "exec" &&
+		oldSe.Options.Command == newSe.Options.Command && reflect.DeepEqual(oldSe.Options.Args, newSe.Options.Args) {
+		return false
+	}
+
+	slog.Warn("Denied to define 'exec' sensor command via web page, set 'exec sensors' in server config to allow it")
+	sendInfo("Exec sensors may be defined in config file only")
+
+	return true
+}
+
 func modifyGroup(id string, action string, gData *GroupData) bool {
 	modified := false
 
@@ -6,6 +6,7 @@ import (
 	"mime"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/maxb-odessa/nonsens/internal/config"
 	"github.com/maxb-odessa/nonsens/internal/sensors"
+	"github.com/maxb-odessa/nonsens/internal/storage"
 	"github.com/maxb-odessa/nonsens/internal/tmpl"
 	"github.com/maxb-odessa/slog"
 )
@@ -27,8 +29,10 @@ var (
 	wsChansLock  sync.Mutex
 	templates    tmpl.Tmpls
 	mainPageData string
+	mainPageLock sync.Mutex
 	conf         *config.Config
 	confBackup   *config.Config
+	confLock     sync.Mutex // conf is changed by clients feedback, serialize its use
 )
 
 func Run(cf *config.Config) error {
@@ -71,6 +75,7 @@ func makeMainPage() error {
 	type PageData struct {
 		HostName string
 		Config   *config.Config
+		Sensors  map[string]string // sensor id -> its current widget, so new clients see values and history at once
 	}
 
 	hostName, err := os.Hostname()
@@ -83,11 +88,30 @@ func makeMainPage() error {
 	data := PageData{
 		HostName: strings.ToUpper(hostName),
 		Config:   conf,
+		Sensors:  make(map[string]string),
 	}
 
-	mainPageData, err = tmpl.ApplyByName("main", templates, data)
+	for _, sens := range conf.AllSensors() {
+		sens.Lock()
+		body, err := tmpl.ApplyByName("sensor", templates, sens)
+		sens.Unlock()
+		if err != nil {
+			slog.Warn("Templating sensor failed: %s", err)
+			continue
+		}
+		data.Sensors[sens.Id()] = body
+	}
+
+	page, err := tmpl.ApplyByName("main", templates, data)
+	if err != nil {
+		return err
+	}
+
+	mainPageLock.Lock()
+	mainPageData = page
+	mainPageLock.Unlock()
 
-	return err
+	return nil
 }
 
 type ToClientMsg struct {
@@ -118,10 +142,12 @@ func GetHostName() string {
 
 func sendMainPage() {
 
+	mainPageLock.Lock()
 	msg := &ToClientMsg{
 		Target: "main",
 		Data:   mainPageData,
 	}
+	mainPageLock.Unlock()
 
 	data, _ := json.Marshal(msg)
 
@@ -167,11 +193,11 @@ func sendSysinfo() {
 		default:
 		}
 
-		// send mem stats
-		mem, _ := sysstats.GetMemStats()
+		// send mem stats: available (not just free) memory is what matters
+		mem, _ := sensors.ReadMeminfo()
 		msg = &ToClientMsg{
 			Target: "sysinfo-mem",
-			Data:   fmt.Sprintf("Free: %d of %d MBytes", (mem["memused"]-mem["cached"]-mem["buffers"])/1024, mem["memtotal"]/1024),
+			Data:   fmt.Sprintf("Free: %.0f of %.0f MBytes", mem["MemAvailable"]/1024, mem["MemTotal"]/1024),
 		}
 		data, _ = json.Marshal(msg)
 
@@ -194,7 +220,7 @@ func sendSysinfo() {
 
 func sendSensorsData() {
 
-	sensChan := sensors.Chan()
+	sensChan := sensors.Updates()
 
 	for sens := range sensChan {
 
@@ -271,7 +297,16 @@ func server() {
 
 		go reader()
 
-		go sendMainPage() // this blocks if chan is full
+		// refresh sensors widgets for the new client
+		go func() {
+			confLock.Lock()
+			err := makeMainPage()
+			confLock.Unlock()
+			if err != nil {
+				slog.Warn("Making main page failed: %s", err)
+			}
+			sendMainPage() // this blocks if chan is full
+		}()
 
 		for {
 			select {
@@ -290,6 +325,7 @@ func server() {
 		}
 	}
 	router.HandleFunc("/ws", wsHandler)
+	router.HandleFunc("/values", valuesHandler)
 
 	pageDir := os.ExpandEnv(conf.Server.Resources + "/webpage")
 	slog.Info("Serving HTTP dir: %s", pageDir)
@@ -313,6 +349,70 @@ func server() {
 	sr.ListenAndServe()
 }
 
+// stored sensor values: /values?sensor=<id>&tier=raw|1m|1h&from=<unix time>&to=<unix time>,
+// the last day of 1m values by default
+func valuesHandler(w http.ResponseWriter, r *http.Request) {
+
+	args := r.URL.Query()
+
+	confLock.Lock()
+	_, sens := conf.FindSensorById(args.Get("sensor"))
+	confLock.Unlock()
+	if sens == nil {
+		http.Error(w, "sensor not found", http.StatusNotFound)
+		return
+	}
+
+	tier := args.Get("tier")
+	if tier == "" {
+		tier = "1m"
+	}
+
+	to := time.Now()
+	if arg := args.Get("to"); arg != "" {
+		sec, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid 'to' time", http.StatusBadRequest)
+			return
+		}
+		to = time.Unix(sec, 0)
+	}
+
+	from := to.Add(-24 * time.Hour)
+	if arg := args.Get("from"); arg != "" {
+		sec, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid 'from' time", http.StatusBadRequest)
+			return
+		}
+		from = time.Unix(sec, 0)
+	}
+
+	sens.Lock()
+	key := sens.Key()
+	reply := struct {
+		Name   string          `json:"name"`
+		Units  string          `json:"units"`
+		Tier   string          `json:"tier"`
+		Points []storage.Point `json:"points"`
+	}{
+		Name:  sens.Widget.Name,
+		Units: sens.Widget.Units,
+		Tier:  tier,
+	}
+	sens.Unlock()
+
+	points, err := storage.Query(key, tier, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reply.Points = points
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
 func registerChan(ch chan []byte, id string) {
 	wsChansLock.Lock()
 	wsChans[id] = ch
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maxb-odessa/slog"
+)
+
+// segment file record:
+// header:  payload length (uint32), payload crc32 (uint32)
+// payload: time in unix ms (int64), values count (uint32), min, avg, max (float64), key (the rest)
+const (
+	HEADER_SIZE  = 8
+	PAYLOAD_SIZE = 8 + 4 + 8*3
+	MAX_KEY_SIZE = 4096
+	SEGMENT_EXT  = ".seg"
+)
+
+// segment file being appended to
+type segment struct {
+	start time.Time // segment start time, its file is named by it
+	size  int64     // size of records written
+	fp    *os.File
+	w     *bufio.Writer
+}
+
+func segmentPath(dir string, start time.Time) string {
+	return filepath.Join(dir, strconv.FormatInt(start.Unix(), 10)+SEGMENT_EXT)
+}
+
+// list segments files start times of the dir, oldest first
+func listSegments(dir string) []time.Time {
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+SEGMENT_EXT))
+
+	starts := make([]time.Time, 0, len(files))
+	for _, file := range files {
+		if sec, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(file), SEGMENT_EXT), 10, 64); err == nil {
+			starts = append(starts, time.Unix(sec, 0))
+		}
+	}
+
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	return starts
+}
+
+// open segment for appending, partially written or damaged tail (i.e. after a crash) is cut off
+func openSegment(dir string, start time.Time) (*segment, error) {
+
+	path := segmentPath(dir, start)
+
+	fp, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := readRecords(fp, func(string, Point) {})
+	if err != nil {
+		slog.Warn("Storage segment '%s' is damaged at offset %d, truncating: %s", path, size, err)
+		if err := fp.Truncate(size); err != nil {
+			fp.Close()
+			return nil, err
+		}
+	}
+
+	if _, err := fp.Seek(size, io.SeekStart); err != nil {
+		fp.Close()
+		return nil, err
+	}
+
+	return &segment{start: start, size: size, fp: fp, w: bufio.NewWriter(fp)}, nil
+}
+
+func (seg *segment) write(key string, p Point) error {
+
+	payload := make([]byte, PAYLOAD_SIZE, PAYLOAD_SIZE+len(key))
+	binary.LittleEndian.PutUint64(payload[0:], uint64(p.Time.UnixMilli()))
+	binary.LittleEndian.PutUint32(payload[8:], p.Count)
+	binary.LittleEndian.PutUint64(payload[12:], math.Float64bits(p.Min))
+	binary.LittleEndian.PutUint64(payload[20:], math.Float64bits(p.Avg))
+	binary.LittleEndian.PutUint64(payload[28:], math.Float64bits(p.Max))
+	payload = append(payload, key...)
+
+	header := make([]byte, HEADER_SIZE)
+	binary.LittleEndian.PutUint32(header[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(header[4:], crc32.ChecksumIEEE(payload))
+
+	if _, err := seg.w.Write(header); err != nil {
+		return err
+	}
+
+	_, err := seg.w.Write(payload)
+	if err == nil {
+		seg.size += HEADER_SIZE + int64(len(payload))
+	}
+
+	return err
+}
+
+// write buffered records to file
+func (seg *segment) flush() error {
+	return seg.w.Flush()
+}
+
+// write buffered records to disk
+func (seg *segment) sync() error {
+	if err := seg.flush(); err != nil {
+		return err
+	}
+	return seg.fp.Sync()
+}
+
+func (seg *segment) close() error {
+	err := seg.sync()
+	if err2 := seg.fp.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// read segment file records, up to size bytes if it is not negative (the rest may be being written)
+func readSegment(dir string, start time.Time, size int64, fn func(key string, p Point)) error {
+
+	path := segmentPath(dir, start)
+
+	fp, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	var r io.Reader = fp
+	if size >= 0 {
+		r = io.LimitReader(fp, size)
+	}
+
+	if size, err := readRecords(r, fn); err != nil {
+		// the rest of segment is lost, but its good records are still usable
+		slog.Warn("Storage segment '%s' is damaged at offset %d: %s", path, size, err)
+	}
+
+	return nil
+}
+
+// read records from the current position, return size of good records read and an error if any
+func readRecords(r io.Reader, fn func(key string, p Point)) (int64, error) {
+
+	br := bufio.NewReader(r)
+	header := make([]byte, HEADER_SIZE)
+	size := int64(0)
+
+	for {
+
+		if _, err := io.ReadFull(br, header); err == io.EOF {
+			return size, nil
+		} else if err != nil {
+			return size, err
+		}
+
+		length := binary.LittleEndian.Uint32(header[0:])
+		if length < PAYLOAD_SIZE || length > PAYLOAD_SIZE+MAX_KEY_SIZE {
+			return size, fmt.Errorf("invalid record length %d", length)
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return size, err
+		}
+
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:]) {
+			return size, errors.New("record checksum mismatch")
+		}
+
+		fn(string(payload[PAYLOAD_SIZE:]), Point{
+			Time:  time.UnixMilli(int64(binary.LittleEndian.Uint64(payload[0:]))),
+			Count: binary.LittleEndian.Uint32(payload[8:]),
+			Min:   math.Float64frombits(binary.LittleEndian.Uint64(payload[12:])),
+			Avg:   math.Float64frombits(binary.LittleEndian.Uint64(payload[20:])),
+			Max:   math.Float64frombits(binary.LittleEndian.Uint64(payload[28:])),
+		})
+
+		size += HEADER_SIZE + int64(length)
+	}
+}
@@ -0,0 +1,483 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/maxb-odessa/nonsens/internal/config"
+
+	"github.com/maxb-odessa/slog"
+)
+
+// default tiers retention, in hours
+const (
+	RAW_RETENTION    = 1
+	MINUTE_RETENTION = 8 * 24
+	HOUR_RETENTION   = 366 * 24
+)
+
+// how often buffered records are written to disk and expired segments are removed
+const FLUSH_INTERVAL = 10 * time.Second
+
+// stored value: a sample for "raw" tier, min/avg/max of a period for the others
+type Point struct {
+	Time  time.Time `json:"time"`  // sample time or period start
+	Min   float64   `json:"min"`   // min value
+	Avg   float64   `json:"avg"`   // average value
+	Max   float64   `json:"max"`   // max value
+	Count uint32    `json:"count"` // number of samples
+}
+
+// merge another point values into this one
+func (p *Point) merge(p2 Point) {
+	if p.Count == 0 {
+		start := p.Time
+		*p = p2
+		p.Time = start
+		return
+	}
+	p.Min = math.Min(p.Min, p2.Min)
+	p.Max = math.Max(p.Max, p2.Max)
+	p.Avg = (p.Avg*float64(p.Count) + p2.Avg*float64(p2.Count)) / float64(p.Count+p2.Count)
+	p.Count += p2.Count
+}
+
+// storage tier: values of some resolution kept for some time
+type tier struct {
+	name       string
+	resolution time.Duration     // period values are rolled up to, 0 for raw samples
+	span       time.Duration     // time span of one segment file
+	retention  time.Duration     // how long to keep values
+	dir        string            // segment files dir
+	seg        *segment          // segment being appended to
+	bucket     time.Time         // start of the period being rolled up
+	points     map[string]*Point // period being rolled up, by key
+	next       *tier             // rolled up values go there
+}
+
+var storage struct {
+	sync.Mutex
+	tiers   []*tier    // raw, then coarser ones
+	closing []*segment // segments switched from, synced and closed by flusher
+	done    chan bool
+}
+
+// open the storage configured in config, it is disabled if no dir is set
+func Run(conf *config.Config) error {
+
+	if conf.Storage == nil || conf.Storage.Dir == "" {
+		slog.Info("Values storage is disabled")
+		return nil
+	}
+
+	dir := os.ExpandEnv(conf.Storage.Dir)
+
+	retention := func(hours int, def int) time.Duration {
+		if hours <= 0 {
+			hours = def
+		}
+		return time.Duration(hours) * time.Hour
+	}
+
+	tiers := []*tier{
+		{name: "raw", span: 10 * time.Minute, retention: retention(conf.Storage.RawRetention, RAW_RETENTION)},
+		{name: "1m", resolution: time.Minute, span: 24 * time.Hour, retention: retention(conf.Storage.MinuteRetention, MINUTE_RETENTION)},
+		{name: "1h", resolution: time.Hour, span: 30 * 24 * time.Hour, retention: retention(conf.Storage.HourRetention, HOUR_RETENTION)},
+	}
+
+	for i, t := range tiers {
+		t.dir = filepath.Join(dir, t.name)
+		if err := os.MkdirAll(t.dir, 0755); err != nil {
+			return err
+		}
+		if i+1 < len(tiers) {
+			t.next = tiers[i+1]
+		}
+	}
+
+	storage.Lock()
+	defer storage.Unlock()
+
+	now := time.Now()
+
+	// roll up the values stored before the last shutdown or crash
+	for i := 1; i < len(tiers); i++ {
+		if err := tiers[i].catchUp(tiers[i-1], now); err != nil {
+			return err
+		}
+	}
+
+	storage.tiers = tiers
+	storage.done = make(chan bool)
+
+	go flusher(storage.done)
+
+	slog.Info("Storing sensors values to '%s'", dir)
+
+	return nil
+}
+
+// write buffered records periodically, remove expired segments
+func flusher(done chan bool) {
+
+	ticker := time.NewTicker(FLUSH_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+
+			// only buffered records are written under the lock, syncing to disk may take a while
+			storage.Lock()
+			tiers := storage.tiers
+			closing := storage.closing
+			storage.closing = nil
+			files := make([]*os.File, 0, len(tiers))
+			for _, t := range tiers {
+				t.advance(now)
+				if t.seg != nil {
+					if err := t.seg.flush(); err != nil {
+						slog.Warn("Storage write failed: %s", err)
+					}
+					files = append(files, t.seg.fp)
+				}
+			}
+			storage.Unlock()
+
+			closeSegments(closing)
+
+			for _, fp := range files {
+				// segment may be closed meanwhile, it is synced on close
+				if err := fp.Sync(); err != nil && !errors.Is(err, os.ErrClosed) {
+					slog.Warn("Storage sync failed: %s", err)
+				}
+			}
+
+			for _, t := range tiers {
+				t.expire(now)
+			}
+		}
+	}
+}
+
+// flush all the data and close the storage
+func Close() {
+
+	storage.Lock()
+	defer storage.Unlock()
+
+	if storage.tiers == nil {
+		return
+	}
+
+	close(storage.done)
+
+	closeSegments(storage.closing)
+	storage.closing = nil
+
+	for _, t := range storage.tiers {
+		t.sync()
+		if t.seg != nil {
+			t.seg.close()
+			t.seg = nil
+		}
+	}
+
+	storage.tiers = nil
+}
+
+// store the sensor value identified by key
+func Add(key string, tm time.Time, value float64) {
+
+	storage.Lock()
+	defer storage.Unlock()
+
+	if storage.tiers == nil {
+		return
+	}
+
+	raw := storage.tiers[0]
+	p := Point{Time: tm, Min: value, Avg: value, Max: value, Count: 1}
+
+	if err := raw.write(key, p); err != nil {
+		slog.Warn("Storage write failed: %s", err)
+	}
+
+	raw.next.advance(tm)
+	raw.next.add(key, p)
+}
+
+// get stored values of the key in the tier for from..to period, oldest first
+func Query(key string, tierName string, from time.Time, to time.Time) ([]Point, error) {
+
+	storage.Lock()
+
+	if storage.tiers == nil {
+		storage.Unlock()
+		return nil, fmt.Errorf("storage is disabled")
+	}
+
+	var t *tier
+	for _, tt := range storage.tiers {
+		if tt.name == tierName {
+			t = tt
+		}
+	}
+
+	if t == nil {
+		storage.Unlock()
+		return nil, fmt.Errorf("unknown tier '%s'", tierName)
+	}
+
+	// segments are read without the lock: take the records written so far and the period being rolled up
+	sizes := make(map[int64]int64)
+	if t.seg != nil {
+		if err := t.seg.flush(); err != nil {
+			slog.Warn("Storage write failed: %s", err)
+		}
+		sizes[t.seg.start.Unix()] = t.seg.size
+	}
+
+	var current *Point
+	if p, ok := t.points[key]; ok {
+		pp := *p
+		current = &pp
+	}
+
+	storage.Unlock()
+
+	// the same period may be rolled up again after a crash, the last record wins
+	byTime := make(map[int64]Point)
+
+	t.read(from, to, sizes, func(k string, p Point) {
+		if k == key {
+			byTime[p.Time.UnixMilli()] = p
+		}
+	})
+
+	if current != nil && !current.Time.Before(from) && current.Time.Before(to) {
+		byTime[current.Time.UnixMilli()] = *current
+	}
+
+	points := make([]Point, 0, len(byTime))
+	for _, p := range byTime {
+		points = append(points, p)
+	}
+
+	sort.Slice(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+
+	return points, nil
+}
+
+// read tier records of from..to period, segments listed in sizes (by start time) are read up to their sizes
+func (t *tier) read(from time.Time, to time.Time, sizes map[int64]int64, fn func(key string, p Point)) {
+	for _, start := range listSegments(t.dir) {
+		if !start.Before(to) || !start.Add(t.span).After(from) {
+			continue
+		}
+		size, ok := sizes[start.Unix()]
+		if !ok {
+			size = -1
+		}
+		err := readSegment(t.dir, start, size, func(key string, p Point) {
+			if !p.Time.Before(from) && p.Time.Before(to) {
+				fn(key, p)
+			}
+		})
+		// segment may be expired meanwhile
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Storage read failed: %s", err)
+		}
+	}
+}
+
+// sync and close segments
+func closeSegments(segs []*segment) {
+	for _, seg := range segs {
+		if err := seg.close(); err != nil {
+			slog.Warn("Storage sync failed: %s", err)
+		}
+	}
+}
+
+// append a record, switch to another segment file if needed, storage must be locked
+func (t *tier) write(key string, p Point) error {
+
+	start := p.Time.Truncate(t.span)
+
+	if t.seg == nil || !t.seg.start.Equal(start) {
+		if t.seg != nil {
+			// syncing to disk may take a while, leave it to flusher
+			if err := t.seg.flush(); err != nil {
+				slog.Warn("Storage write failed: %s", err)
+			}
+			storage.closing = append(storage.closing, t.seg)
+			t.seg = nil
+		}
+		seg, err := openSegment(t.dir, start)
+		if err != nil {
+			return err
+		}
+		t.seg = seg
+	}
+
+	return t.seg.write(key, p)
+}
+
+func (t *tier) sync() {
+	if t.seg != nil {
+		if err := t.seg.sync(); err != nil {
+			slog.Warn("Storage sync failed: %s", err)
+		}
+	}
+}
+
+// remove segments older than tier retention, storage must not be locked
+func (t *tier) expire(now time.Time) {
+	for _, start := range listSegments(t.dir) {
+		if start.Add(t.span).Before(now.Add(-t.retention)) {
+			storage.Lock()
+			seg := t.seg
+			if seg != nil && seg.start.Equal(start) {
+				t.seg = nil
+			} else {
+				seg = nil
+			}
+			storage.Unlock()
+			if seg != nil {
+				seg.close()
+			}
+			slog.Debug(1, "Removing expired storage segment '%s'", segmentPath(t.dir, start))
+			os.Remove(segmentPath(t.dir, start))
+		}
+	}
+}
+
+// add a value to the period being rolled up
+func (t *tier) add(key string, p Point) {
+
+	if t.points == nil {
+		t.points = make(map[string]*Point)
+	}
+
+	if _, ok := t.points[key]; !ok {
+		t.points[key] = &Point{Time: t.bucket}
+	}
+
+	t.points[key].merge(p)
+}
+
+// finish rolling up the period if the time is past it: store its values and pass them to the next tier
+func (t *tier) advance(now time.Time) {
+
+	if t.resolution == 0 {
+		return
+	}
+
+	bucket := now.Truncate(t.resolution)
+	if !bucket.After(t.bucket) {
+		return
+	}
+
+	keys := make([]string, 0, len(t.points))
+	for key := range t.points {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if t.next != nil {
+		t.next.advance(t.bucket)
+	}
+
+	for _, key := range keys {
+		p := *t.points[key]
+		if err := t.write(key, p); err != nil {
+			slog.Warn("Storage write failed: %s", err)
+		}
+		if t.next != nil {
+			t.next.add(key, p)
+		}
+	}
+
+	t.bucket = bucket
+	t.points = nil
+}
+
+// roll up the source tier values stored since the last period of this tier,
+// start rolling up the current period with its values
+func (t *tier) catchUp(src *tier, now time.Time) error {
+
+	// find the last rolled up period, it is rolled up again as it may be incomplete
+	var from time.Time
+	starts := listSegments(t.dir)
+	for i := len(starts) - 1; i >= 0 && from.IsZero(); i-- {
+		readSegment(t.dir, starts[i], -1, func(key string, p Point) {
+			if p.Time.After(from) {
+				from = p.Time
+			}
+		})
+	}
+
+	current := now.Truncate(t.resolution)
+
+	// source periods may be rolled up again after a crash, the last record wins
+	type record struct {
+		key  string
+		time int64
+	}
+	records := make(map[record]Point)
+
+	src.read(from, now, nil, func(key string, p Point) {
+		records[record{key, p.Time.UnixMilli()}] = p
+	})
+
+	t.bucket = current
+	points := make(map[int64]map[string]*Point)
+	rolled := 0
+
+	for rec, p := range records {
+		bucket := p.Time.Truncate(t.resolution)
+		if !bucket.Before(current) {
+			t.add(rec.key, p)
+			continue
+		}
+		if points[bucket.Unix()] == nil {
+			points[bucket.Unix()] = make(map[string]*Point)
+		}
+		if points[bucket.Unix()][rec.key] == nil {
+			points[bucket.Unix()][rec.key] = &Point{Time: bucket}
+		}
+		points[bucket.Unix()][rec.key].merge(p)
+		rolled++
+	}
+
+	buckets := make([]int64, 0, len(points))
+	for bucket := range points {
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+
+	for _, bucket := range buckets {
+		for key, p := range points[bucket] {
+			if err := t.write(key, *p); err != nil {
+				return err
+			}
+		}
+	}
+
+	t.sync()
+
+	if rolled > 0 {
+		slog.Info("Storage: rolled up %d '%s' values into '%s' since %s", rolled, src.name, t.name, from.Format(time.DateTime))
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// some hour start, so periods are easy to follow
+var testBase = time.Unix(1_700_000_000, 0).Truncate(time.Hour)
+
+func testPoint(tm time.Time, value float64) Point {
+	return Point{Time: tm, Min: value, Avg: value, Max: value, Count: 1}
+}
+
+// read all tier records, the last record of a period wins as in Query()
+func testRecords(t *testing.T, tr *tier) map[int64]Point {
+
+	records := make(map[int64]Point)
+
+	for _, start := range listSegments(tr.dir) {
+		err := readSegment(tr.dir, start, -1, func(key string, p Point) {
+			records[p.Time.Unix()] = p
+		})
+		if err != nil {
+			t.Fatalf("reading segment failed: %s", err)
+		}
+	}
+
+	return records
+}
+
+func TestDamagedTail(t *testing.T) {
+
+	dir := t.TempDir()
+
+	seg, err := openSegment(dir, testBase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg.write("key", testPoint(testBase, 1.0))
+	seg.write("key", testPoint(testBase.Add(time.Second), 2.0))
+	if err := seg.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// partially written record, as after a crash
+	fp, err := os.OpenFile(segmentPath(dir, testBase), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp.Write([]byte{PAYLOAD_SIZE + 3, 0, 0, 0, 1, 2, 3, 4, 5, 6})
+	fp.Close()
+
+	seg, err = openSegment(dir, testBase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seg.size != 2*(HEADER_SIZE+PAYLOAD_SIZE+3) {
+		t.Errorf("damaged tail is not cut off, segment size %d", seg.size)
+	}
+	seg.write("key", testPoint(testBase.Add(2*time.Second), 3.0))
+	if err := seg.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var values []float64
+	readSegment(dir, testBase, -1, func(key string, p Point) {
+		values = append(values, p.Avg)
+	})
+
+	if len(values) != 3 || values[0] != 1.0 || values[1] != 2.0 || values[2] != 3.0 {
+		t.Errorf("got values %v, want [1 2 3]", values)
+	}
+}
+
+func TestCatchUpRestart(t *testing.T) {
+
+	dir := t.TempDir()
+
+	tiers := func() (*tier, *tier, *tier) {
+		return &tier{name: "raw", span: 10 * time.Minute, dir: dir + "/raw"},
+			&tier{name: "1m", resolution: time.Minute, span: 24 * time.Hour, dir: dir + "/1m"},
+			&tier{name: "1h", resolution: time.Hour, span: 30 * 24 * time.Hour, dir: dir + "/1h"}
+	}
+
+	closeTiers := func(tiers ...*tier) {
+		for _, tr := range tiers {
+			if tr.seg != nil {
+				tr.seg.close()
+				tr.seg = nil
+			}
+		}
+	}
+
+	raw, min, hour := tiers()
+	for _, tr := range []*tier{raw, min, hour} {
+		if err := os.MkdirAll(tr.dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// two samples of the 1st minute, one of the 2nd and of the 3rd (current) one
+	for _, p := range []Point{
+		testPoint(testBase, 1.0),
+		testPoint(testBase.Add(10*time.Second), 3.0),
+		testPoint(testBase.Add(70*time.Second), 5.0),
+		testPoint(testBase.Add(130*time.Second), 7.0),
+	} {
+		if err := raw.write("key", p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	closeTiers(raw)
+
+	now := testBase.Add(150 * time.Second)
+
+	for i := 0; i < 2; i++ {
+
+		// the 2nd pass is a restart: the last 1m period is rolled up again
+		raw, min, hour = tiers()
+
+		if err := min.catchUp(raw, now); err != nil {
+			t.Fatal(err)
+		}
+		if err := hour.catchUp(min, now); err != nil {
+			t.Fatal(err)
+		}
+
+		if p := min.points["key"]; p == nil || p.Count != 1 || p.Avg != 7.0 {
+			t.Errorf("pass %d: current 1m period is %+v, want 1 value of 7", i, p)
+		}
+
+		if p := hour.points["key"]; p == nil || p.Count != 3 || p.Avg != 3.0 {
+			t.Errorf("pass %d: current 1h period is %+v, want 3 values of 3 average", i, p)
+		}
+
+		closeTiers(min, hour)
+	}
+
+	records := testRecords(t, min)
+	if len(records) != 2 {
+		t.Fatalf("got %d 1m periods, want 2", len(records))
+	}
+	if p := records[testBase.Unix()]; p.Count != 2 || p.Avg != 2.0 || p.Min != 1.0 || p.Max != 3.0 {
+		t.Errorf("1st 1m period is %+v, want 2 values of 1..3", p)
+	}
+	if p := records[testBase.Add(time.Minute).Unix()]; p.Count != 1 || p.Avg != 5.0 {
+		t.Errorf("2nd 1m period is %+v, want 1 value of 5", p)
+	}
+}
+
+func TestExpire(t *testing.T) {
+
+	now := testBase.Add(5 * time.Hour)
+	tr := &tier{name: "raw", span: 10 * time.Minute, retention: time.Hour, dir: t.TempDir()}
+
+	old := now.Add(-3 * time.Hour).Truncate(tr.span)
+	recent := now.Add(-30 * time.Minute).Truncate(tr.span)
+
+	if err := tr.write("key", testPoint(recent, 1.0)); err != nil {
+		t.Fatal(err)
+	}
+	// expired segment being appended to is closed
+	if err := tr.write("key", testPoint(old, 1.0)); err != nil {
+		t.Fatal(err)
+	}
+
+	tr.expire(now)
+
+	if tr.seg != nil {
+		t.Errorf("expired segment is not closed")
+	}
+
+	starts := listSegments(tr.dir)
+	if len(starts) != 1 || !starts[0].Equal(recent) {
+		t.Errorf("got segments %v, want only %v", starts, recent)
+	}
+}